@@ -0,0 +1,68 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// AccelReport summarizes how a finished download used the connection:
+// parallelism and chunking on one side, achieved speed and retries on the
+// other, so users (and adaptive tuning) can see which settings actually
+// help on their link.
+type AccelReport struct {
+	Threads  int     `json:"threads"`
+	PartSize int     `json:"partSize"`
+	Bytes    int64   `json:"bytes"`
+	Seconds  float64 `json:"seconds"`
+	AvgBps   float64 `json:"avgBps"`
+	PeakBps  int64   `json:"peakBps"`
+	Retries  int64   `json:"retries"`
+}
+
+// sampleSpeed tracks a task's peak one-second throughput until stop
+// closes; the maximum lands in the acceleration report.
+func sampleSpeed(task *DownloadTask, stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	last := atomic.LoadInt64(&task.Written)
+	for {
+		select {
+		case <-ticker.C:
+			cur := atomic.LoadInt64(&task.Written)
+			if bps := cur - last; bps > atomic.LoadInt64(&task.peakBps) {
+				atomic.StoreInt64(&task.peakBps, bps)
+			}
+			last = cur
+		case <-stop:
+			return
+		}
+	}
+}
+
+// buildReport assembles a completed task's acceleration report.
+func (d *Downloader) buildReport(task *DownloadTask) *AccelReport {
+	r := &AccelReport{
+		Threads:  d.threads,
+		PartSize: partSizeFor(task),
+		Bytes:    atomic.LoadInt64(&task.Written),
+		PeakBps:  atomic.LoadInt64(&task.peakBps),
+		Retries:  atomic.LoadInt64(&task.retries),
+	}
+	if started := task.started(); !started.IsZero() {
+		r.Seconds = time.Since(started).Seconds()
+	}
+	if r.Seconds > 0 {
+		r.AvgBps = float64(r.Bytes) / r.Seconds
+	}
+	return r
+}
+
+// GetAccelerationReport returns a task's per-file report, or nil while it
+// has not finished yet.
+func (a *App) GetAccelerationReport(id string) *AccelReport {
+	task, ok := a.tasks.Get(id)
+	if !ok {
+		return nil
+	}
+	return task.report()
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Account is the UI-facing description of one signed-in Telegram account.
+type Account struct {
+	Name       string `json:"name"`
+	Active     bool   `json:"active"`
+	Authorized bool   `json:"authorized"`
+}
+
+// AccountManager holds one TGClient per account, each with its own session
+// file under <state dir>/accounts/<name>. The state dir is the config dir
+// for the desktop app, or a profile's subdirectory in server mode.
+type AccountManager struct {
+	baseDir string
+
+	mu      sync.Mutex
+	clients map[string]*TGClient
+	// starting reserves names whose client is still being built, so two
+	// concurrent Adds cannot race a client each onto one session dir.
+	starting map[string]struct{}
+	active   string
+}
+
+// NewAccountManager creates an empty manager storing sessions under
+// baseDir.
+func NewAccountManager(baseDir string) *AccountManager {
+	return &AccountManager{
+		baseDir:  baseDir,
+		clients:  make(map[string]*TGClient),
+		starting: make(map[string]struct{}),
+	}
+}
+
+func (m *AccountManager) sessionDir(name string) (string, error) {
+	if m.baseDir == "" {
+		return "", fmt.Errorf("no state directory")
+	}
+	return filepath.Join(m.baseDir, "accounts", name), nil
+}
+
+// Add creates and starts a client for a new account. The first account
+// added becomes active.
+func (m *AccountManager) Add(ctx context.Context, name string, appID int, appHash string, proxy *ProxyConfig) (*TGClient, error) {
+	m.mu.Lock()
+	if _, exists := m.clients[name]; exists {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("account %q already exists", name)
+	}
+	if _, exists := m.starting[name]; exists {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("account %q is already being added", name)
+	}
+	m.starting[name] = struct{}{}
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.starting, name)
+		m.mu.Unlock()
+	}()
+
+	dir, err := m.sessionDir(name)
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewClient(appID, appHash, ClientOptions{SessionDir: dir, Proxy: proxy})
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Start(ctx); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clients[name] = client
+	if m.active == "" {
+		m.active = name
+	}
+	return client, nil
+}
+
+// Get returns the client for a named account.
+func (m *AccountManager) Get(name string) (*TGClient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	client, ok := m.clients[name]
+	if !ok {
+		return nil, fmt.Errorf("no account %q", name)
+	}
+	return client, nil
+}
+
+// Active returns the active account's name and client, or "" and nil.
+func (m *AccountManager) Active() (string, *TGClient) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.active, m.clients[m.active]
+}
+
+// Switch makes a different account the active one.
+func (m *AccountManager) Switch(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.clients[name]; !ok {
+		return fmt.Errorf("no account %q", name)
+	}
+	m.active = name
+	return nil
+}
+
+// Remove disconnects an account and deletes its session directory.
+func (m *AccountManager) Remove(name string) error {
+	m.mu.Lock()
+	client, ok := m.clients[name]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("no account %q", name)
+	}
+	delete(m.clients, name)
+	if m.active == name {
+		m.active = ""
+		for other := range m.clients {
+			m.active = other
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	client.Stop()
+	return os.RemoveAll(client.StateDir())
+}
+
+// List returns all accounts, sorted by name, with their auth state.
+func (m *AccountManager) List(ctx context.Context) []Account {
+	m.mu.Lock()
+	names := make([]string, 0, len(m.clients))
+	for name := range m.clients {
+		names = append(names, name)
+	}
+	active := m.active
+	clients := make(map[string]*TGClient, len(m.clients))
+	for name, c := range m.clients {
+		clients[name] = c
+	}
+	m.mu.Unlock()
+
+	sort.Strings(names)
+	accounts := make([]Account, 0, len(names))
+	for _, name := range names {
+		authorized, _ := clients[name].Authorized(ctx)
+		accounts = append(accounts, Account{
+			Name:       name,
+			Active:     name == active,
+			Authorized: authorized,
+		})
+	}
+	return accounts
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TransferRecord is one finished (or failed) transfer in the history log.
+type TransferRecord struct {
+	Time       time.Time `json:"time"`
+	ChannelID  int64     `json:"channelId"`
+	Bytes      int64     `json:"bytes"`
+	Seconds    float64   `json:"seconds"`
+	Status     string    `json:"status"` // done or failed
+	ErrorClass string    `json:"errorClass,omitempty"`
+	// Integrity records the post-download verification outcome when one
+	// ran: "verified" or "corrupted".
+	Integrity string `json:"integrity,omitempty"`
+}
+
+// historyName is the state document holding the transfer history.
+const historyName = "history.json"
+
+// transferLog persists transfer outcomes so analytics survive restarts.
+type transferLog struct {
+	mu      sync.Mutex
+	store   stateStore
+	records []TransferRecord
+}
+
+// newTransferLog loads the existing history, if any, from the given state
+// directory.
+func newTransferLog(dir string) *transferLog {
+	l := &transferLog{}
+	if dir == "" {
+		return l
+	}
+	l.store = stateStoreFor(dir)
+	if data, err := l.store.Load(historyName); err != nil {
+		logErrorf("history: load: %v", err)
+	} else if data != nil {
+		if err := json.Unmarshal(data, &l.records); err != nil {
+			logErrorf("history: load: %v", err)
+		}
+	}
+	return l
+}
+
+// Add appends one record and saves the log.
+func (l *transferLog) Add(rec TransferRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, rec)
+	if l.store == nil {
+		return
+	}
+	data, err := json.MarshalIndent(l.records, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := l.store.Store(historyName, data); err != nil {
+		logErrorf("history: save: %v", err)
+	}
+}
+
+// Recent returns the newest limit records, oldest first.
+func (l *transferLog) Recent(limit int) []TransferRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	start := len(l.records) - limit
+	if limit <= 0 || start < 0 {
+		start = 0
+	}
+	out := make([]TransferRecord, len(l.records)-start)
+	copy(out, l.records[start:])
+	return out
+}
+
+// Analytics is what GetAnalytics hands the frontend.
+type Analytics struct {
+	// SpeedByHour is the average transfer speed in bytes per second for
+	// each hour of day, 0 where no data exists.
+	SpeedByHour [24]float64 `json:"speedByHour"`
+	// FailureRateByChannel maps channel ID to the fraction of its
+	// transfers that failed.
+	FailureRateByChannel map[int64]float64 `json:"failureRateByChannel"`
+	// ErrorClasses counts failures per error class.
+	ErrorClasses map[string]int `json:"errorClasses"`
+	Transfers    int            `json:"transfers"`
+}
+
+// Analytics aggregates the history into per-hour speeds, per-channel
+// failure rates and error class counts.
+func (l *transferLog) Analytics() Analytics {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a := Analytics{
+		FailureRateByChannel: make(map[int64]float64),
+		ErrorClasses:         make(map[string]int),
+		Transfers:            len(l.records),
+	}
+	var speedSum, speedCount [24]float64
+	totals := make(map[int64]int)
+	failures := make(map[int64]int)
+	for _, rec := range l.records {
+		if rec.ChannelID != 0 {
+			totals[rec.ChannelID]++
+		}
+		if rec.Status == "failed" {
+			if rec.ChannelID != 0 {
+				failures[rec.ChannelID]++
+			}
+			a.ErrorClasses[rec.ErrorClass]++
+			continue
+		}
+		if rec.Seconds > 0 && rec.Bytes > 0 {
+			hour := rec.Time.Hour()
+			speedSum[hour] += float64(rec.Bytes) / rec.Seconds
+			speedCount[hour]++
+		}
+	}
+	for hour := range speedSum {
+		if speedCount[hour] > 0 {
+			a.SpeedByHour[hour] = speedSum[hour] / speedCount[hour]
+		}
+	}
+	for id, total := range totals {
+		a.FailureRateByChannel[id] = float64(failures[id]) / float64(total)
+	}
+	return a
+}
+
+// errorClass buckets an error message into a coarse class for analytics.
+func errorClass(msg string) string {
+	switch {
+	case strings.Contains(msg, "DISK_FULL"):
+		return "DISK_FULL"
+	case strings.Contains(msg, "FLOOD_WAIT"):
+		return "FLOOD_WAIT"
+	case strings.Contains(msg, "FILE_REFERENCE"):
+		return "FILE_REFERENCE"
+	case strings.Contains(msg, "circuit breaker"):
+		return "CIRCUIT_OPEN"
+	case strings.Contains(msg, "resolve"):
+		return "RESOLVE"
+	case strings.Contains(msg, "context canceled"):
+		return "CANCELLED"
+	case strings.Contains(msg, "no media"):
+		return "NO_MEDIA"
+	case strings.Contains(msg, "not found"), strings.Contains(msg, "no longer carries"):
+		return "EXPIRED"
+	default:
+		return "OTHER"
+	}
+}
@@ -0,0 +1,1322 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// accountBundle is the per-account wiring: one client plus the components
+// bound to it.
+type accountBundle struct {
+	name       string
+	client     *TGClient
+	downloader *Downloader
+	watcher    *Watcher
+	exporter   *ChannelExporter
+}
+
+// App is the Wails binding surface. State-changing methods return a
+// Response whose Code the frontend switches on; see response.go for the
+// stable code set.
+type App struct {
+	ctx context.Context
+
+	// baseDir is this instance's state directory; destDir is the default
+	// destination for queue calls that pass none.
+	baseDir string
+	destDir string
+
+	accounts *AccountManager
+	queue    *DownloadQueue
+	jobs     *JobStore
+	tasks    *taskRegistry
+	audit    *auditLog
+
+	mu           sync.Mutex
+	proxy        *ProxyConfig
+	quality      string
+	nameTemplate string
+	bundles      map[string]*accountBundle
+	nextID       int
+	nextBatchID  int
+	nextUploadID int
+	batches      map[string]*Batch
+	uploads      map[string]*UploadTask
+	bgLimit      *rateLimiter
+	globalLimit  *rateLimiter
+	focusedTask  string
+	scheduler    *bandwidthScheduler
+	history      *transferLog
+	format       formatPrefs
+	progress     *progressGate
+	verifyAll    bool
+	durability   map[string]string
+	useTakeout   bool
+	poolSize     int
+	zeroByte     string
+	threads      int
+	partSize     int
+	// probeCtx scopes in-flight metadata probes; see probeScope.
+	probeCtx    context.Context
+	probeCancel context.CancelFunc
+	// catalog is the active language's message overlay; nil means the
+	// built-in English catalog. See messages.go.
+	catalog map[string]string
+
+	// Embedded streaming server, started on first GetStreamURL call.
+	streamLn     net.Listener
+	streams      map[string]*streamSource
+	nextStreamID int
+
+	// Aggregate-speed sample from the previous GetQueueForecast call.
+	forecastAt      time.Time
+	forecastWritten int64
+}
+
+// NewApp creates the application state bound into the frontend. baseDir is
+// where sessions, queue state and history live; "" means the shared config
+// dir, while server-mode profiles pass their own subdirectory for
+// isolation.
+func NewApp(baseDir string) *App {
+	if baseDir == "" {
+		dir, err := configDir()
+		if err != nil {
+			logErrorf("config: %v (state persistence disabled)", err)
+		} else {
+			baseDir = dir
+		}
+	}
+	jobs, err := NewJobStore(baseDir)
+	if err != nil {
+		logErrorf("jobs: %v (job persistence disabled)", err)
+		jobs = &JobStore{jobs: make(map[string]*Job)}
+	}
+	a := &App{
+		baseDir:     baseDir,
+		destDir:     overrides.DestDir,
+		accounts:    NewAccountManager(baseDir),
+		queue:       NewDownloadQueue(defaultMaxConcurrent),
+		jobs:        jobs,
+		tasks:       newTaskRegistry(),
+		audit:       newAuditLog(baseDir),
+		bundles:     make(map[string]*accountBundle),
+		batches:     make(map[string]*Batch),
+		bgLimit:     newRateLimiter(0),
+		globalLimit: newRateLimiter(0),
+	}
+	a.scheduler = newBandwidthScheduler(baseDir, a.globalLimit.SetRate, a.queue.SetPaused)
+	a.history = newTransferLog(baseDir)
+	a.progress = newProgressGate()
+	a.queue.SetEmit(a.emit)
+	a.applyOverrides()
+	restored, err := loadTasks(baseDir)
+	if err != nil {
+		logErrorf("tasks: load: %v", err)
+	}
+	for _, task := range restored {
+		a.tasks.Add(task)
+		if n := taskNumber(task.ID); n > a.nextID {
+			a.nextID = n
+		}
+	}
+	return a
+}
+
+func (a *App) startup(ctx context.Context) {
+	a.ctx = ctx
+	go a.watchDeadlines(ctx)
+	go a.watchSchedules(ctx)
+	go a.watchSettings(ctx)
+	go a.watchSessions(ctx)
+}
+
+// deadlineTick is how often task deadlines are checked.
+const deadlineTick = 15 * time.Second
+
+// watchDeadlines pauses tasks whose deadline has passed, so a download
+// sharing a scheduled connection stops on time instead of running over.
+func (a *App) watchDeadlines(ctx context.Context) {
+	ticker := time.NewTicker(deadlineTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			a.enforceDeadlines(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (a *App) enforceDeadlines(now time.Time) {
+	var missed []*DownloadTask
+	for _, task := range a.tasks.List() {
+		deadline := task.deadlineTime()
+		if deadline.IsZero() || now.Before(deadline) {
+			continue
+		}
+		if s := task.status(); s == "running" || s == "queued" {
+			missed = append(missed, task)
+		}
+	}
+
+	for _, task := range missed {
+		if a.queue.Remove(task.ID) {
+			task.setStatus("paused")
+		} else {
+			task.Pause()
+		}
+		a.emit("deadline_missed", task)
+		logWarnf("download %s: deadline %s missed, paused", task.ID, task.deadlineTime().Format(time.RFC3339))
+	}
+}
+
+func (a *App) shutdown(ctx context.Context) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	saveTasks(a.baseDir, a.tasks.Snapshot())
+	if a.streamLn != nil {
+		a.streamLn.Close()
+	}
+	for _, b := range a.bundles {
+		b.client.Stop()
+	}
+}
+
+func (a *App) emit(event string, data interface{}) {
+	if event == "task:progress" {
+		if task, ok := data.(*DownloadTask); ok && !a.progress.Allow("ui", task) {
+			return
+		}
+	}
+	a.emitEvent(event, data)
+}
+
+// active returns the bundle for the active account, or nil when no account
+// is connected yet.
+func (a *App) active() *accountBundle {
+	name, _ := a.accounts.Active()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.bundles[name]
+}
+
+// bundleFor resolves an account name to its bundle, defaulting to the
+// active account for "".
+func (a *App) bundleFor(account string) *accountBundle {
+	if account == "" {
+		return a.active()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.bundles[account]
+}
+
+// Connect opens the MTProto connection for the default account. Kept as
+// the single-account entry point the login screen uses.
+func (a *App) Connect(appID int, appHash string) Response {
+	return a.AddAccount("default", appID, appHash)
+}
+
+// AddAccount creates a new account with its own session file and connects
+// it. Details carries "authorized" when the stored session is already
+// signed in.
+func (a *App) AddAccount(name string, appID int, appHash string) Response {
+	if name == "" {
+		return respErr(CodeInvalidArgument, "account name required")
+	}
+	// Headless deployments supply credentials via -app-id/-app-hash or the
+	// TELETURBO_APP_ID/TELETURBO_APP_HASH environment variables.
+	if appID == 0 {
+		appID = overrides.AppID
+	}
+	if appHash == "" {
+		appHash = overrides.AppHash
+	}
+	a.mu.Lock()
+	proxyCfg := a.proxy
+	a.mu.Unlock()
+	client, err := a.accounts.Add(context.Background(), name, appID, appHash, proxyCfg)
+	if err != nil {
+		return respError(err)
+	}
+	a.audit.Record("account:add", name)
+	dl := NewDownloader(client, 4, a.emit)
+	dl.SetBackgroundLimit(a.bgLimit, a.isTaskFocused)
+	dl.SetGlobalLimit(a.globalLimit)
+	dl.SetHistory(a.history)
+	dl.SetDurability(a.durabilityFor)
+	a.mu.Lock()
+	dl.SetVerifyAll(a.verifyAll)
+	dl.SetZeroBytePolicy(a.zeroByte)
+	a.mu.Unlock()
+	b := &accountBundle{
+		name:       name,
+		client:     client,
+		downloader: dl,
+		watcher:    NewWatcher(a, client, dl, name),
+		exporter:   NewChannelExporter(client, dl),
+	}
+	a.mu.Lock()
+	b.exporter.SetUseTakeout(a.useTakeout)
+	client.SetPoolSize(a.poolSize)
+	a.bundles[name] = b
+	a.mu.Unlock()
+
+	authorized, err := client.Authorized(a.ctx)
+	if err != nil {
+		return respError(err)
+	}
+	if authorized {
+		go func() {
+			// Stay invisible by default; SetOnlineStatus opts in.
+			if err := client.SetPresence(context.Background(), false); err != nil {
+				logWarnf("presence: %v", err)
+			}
+			if st, err := client.ResumeTakeout(context.Background()); err != nil {
+				logWarnf("takeout: resume: %v", err)
+			} else if st.SessionID != 0 {
+				a.emit("takeout:ready", st)
+			}
+			b.watcher.WarmCache(context.Background(), client, a.recentLinks())
+			b.watcher.Backfill(context.Background())
+		}()
+		return Response{Code: CodeOK, Details: "authorized"}
+	}
+	return respOK()
+}
+
+// SetProxy stores the proxy used for subsequently connected accounts.
+// Pass an empty type to go back to a direct connection. Already-connected
+// accounts keep their current transport until reconnected.
+func (a *App) SetProxy(cfg ProxyConfig) Response {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if cfg.Type == "" {
+		a.proxy = nil
+		return respOK()
+	}
+	if err := cfg.validate(); err != nil {
+		return respError(err)
+	}
+	a.proxy = &cfg
+	return respOK()
+}
+
+// ImportProxyLink parses a tg://proxy, t.me/proxy or tg://socks share link
+// and stores the resulting proxy settings.
+func (a *App) ImportProxyLink(link string) Response {
+	cfg, err := ParseProxyLink(link)
+	if err != nil {
+		return respError(err)
+	}
+	return a.SetProxy(*cfg)
+}
+
+// TestProxy checks the given proxy can reach a Telegram data center.
+func (a *App) TestProxy(cfg ProxyConfig) Response {
+	if err := cfg.Test(a.ctx); err != nil {
+		return respError(err)
+	}
+	return respOK()
+}
+
+// ListAccounts returns all configured accounts and their auth state.
+func (a *App) ListAccounts() []Account {
+	return a.accounts.List(a.ctx)
+}
+
+// SwitchAccount changes which account bindings operate on by default. The
+// outgoing account's session is snapshotted first; see sessionbackup.go.
+func (a *App) SwitchAccount(name string) Response {
+	if current, _ := a.accounts.Active(); current != "" && current != name {
+		a.backupSession(current)
+	}
+	if err := a.accounts.Switch(name); err != nil {
+		return respError(err)
+	}
+	return respOK()
+}
+
+// RemoveAccount disconnects an account and deletes its session. A backup
+// is taken first so a hard-won login can be restored if this was a
+// mistake.
+func (a *App) RemoveAccount(name string) Response {
+	a.backupSession(name)
+	if err := a.accounts.Remove(name); err != nil {
+		return respError(err)
+	}
+	a.audit.Record("account:remove", name)
+	a.mu.Lock()
+	delete(a.bundles, name)
+	a.mu.Unlock()
+	return respOK()
+}
+
+// recentLinks collects the links of tasks already known to the app so the
+// resolution cache warm covers recent destinations too.
+func (a *App) recentLinks() []*LinkInfo {
+	tasks := a.tasks.List()
+	links := make([]*LinkInfo, 0, len(tasks))
+	for _, task := range tasks {
+		if task.Link.Peer != "" {
+			continue // non-channel sources have nothing to pre-resolve
+		}
+		links = append(links, task.Link)
+	}
+	return links
+}
+
+// ResetSession deletes the active account's stored session, for the
+// corrupted-session case. The user must Connect again afterwards. The
+// session is backed up first in case it was healthier than it looked.
+func (a *App) ResetSession() Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	a.backupSession(b.name)
+	if err := b.client.ResetSession(); err != nil {
+		return respError(err)
+	}
+	a.RemoveAccount(b.name)
+	return respOK()
+}
+
+// SendCode starts login for the given phone number on the active account.
+func (a *App) SendCode(phone string) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	if err := b.client.SendCode(a.ctx, phone); err != nil {
+		return respError(err)
+	}
+	return Response{Code: CodeCodeSent}
+}
+
+// SignIn completes login with the OTP code on the active account.
+func (a *App) SignIn(code string) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	if err := b.client.SignIn(a.ctx, code); err != nil {
+		return respError(err)
+	}
+	a.audit.Record("login", b.name)
+	return respOK()
+}
+
+// StartDownload parses the link and queues a download into destDir on the
+// active account, returning the new task's ID.
+func (a *App) StartDownload(link, destDir string) Response {
+	return a.QueueDownload(link, destDir, "", 0)
+}
+
+// StartDownloadAs is StartDownload pinned to a specific account, so work
+// and personal channels can download through their own sessions.
+func (a *App) StartDownloadAs(link, destDir, account string) Response {
+	return a.QueueDownload(link, destDir, account, 0)
+}
+
+// QueueDownload enqueues a download with an explicit priority; higher
+// priorities start first when the concurrency cap is reached.
+func (a *App) QueueDownload(link, destDir, account string, priority int) Response {
+	b := a.bundleFor(account)
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	info, err := ParseLink(link)
+	if err != nil {
+		return respError(err)
+	}
+	if info.InviteHash != "" {
+		return respErr(CodeLinkInvalid, "invite link; join the channel first via JoinChannel")
+	}
+	task := a.newTask(info, destDir, b.name)
+	a.queue.Enqueue(task, priority, func(ctx context.Context) error {
+		return b.downloader.Run(ctx, task)
+	})
+	a.audit.Record("queue", task.ID+" "+link)
+	return respID(task.ID)
+}
+
+// StartRangeDownload queues a download of only the first rangeBytes of a
+// document — e.g. the opening 50 MB of a video to judge its quality
+// before committing to all of it. The result lands under a .partial
+// suffix; PromotePartial turns it into a full download later.
+func (a *App) StartRangeDownload(link, destDir string, rangeBytes int64) Response {
+	if rangeBytes <= 0 {
+		return respErr(CodeInvalidArgument, "rangeBytes must be positive")
+	}
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	info, err := ParseLink(link)
+	if err != nil {
+		return respError(err)
+	}
+	if info.InviteHash != "" {
+		return respErr(CodeLinkInvalid, "invite link; join the channel first via JoinChannel")
+	}
+	task := a.newTask(info, destDir, b.name)
+	task.RangeBytes = rangeBytes
+	a.queue.Enqueue(task, 0, func(ctx context.Context) error {
+		return b.downloader.Run(ctx, task)
+	})
+	a.audit.Record("queue", fmt.Sprintf("%s %s first %d bytes", task.ID, link, rangeBytes))
+	return respID(task.ID)
+}
+
+// PromotePartial upgrades a finished range download into a full one: the
+// .partial file becomes the .part file Resume anchors on, so the bytes
+// already on disk are reused instead of fetched again.
+func (a *App) PromotePartial(id string) Response {
+	task, ok := a.tasks.Get(id)
+	if !ok {
+		return respErr(CodeNotFound, "no such task "+id)
+	}
+	if task.RangeBytes == 0 || task.status() != "done" {
+		return respErr(CodeConflict, "task is not a finished range download")
+	}
+	name, _ := task.fileMeta()
+	path := filepath.Join(task.DestDir, name)
+	if err := os.Rename(path+partialSuffix, path+partSuffix); err != nil {
+		return respError(err)
+	}
+	task.mu.Lock()
+	task.RangeBytes = 0
+	task.mu.Unlock()
+	dl := a.downloaderFor(task)
+	if dl == nil {
+		return respErr(CodeNotFound, "task account is gone")
+	}
+	a.audit.Record("resume", id+" promoted to full download")
+	go dl.Resume(context.Background(), task)
+	return respOK()
+}
+
+// JoinChannel joins the channel behind an invite link (t.me/+HASH or
+// t.me/joinchat/HASH) on the active account. Only called after the user
+// confirmed the join in the UI. Returns the channel ID, which the frontend
+// uses to build t.me/c/<id>/<msg> links for downloading right away.
+func (a *App) JoinChannel(link string) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	info, err := ParseLink(link)
+	if err != nil {
+		return respError(err)
+	}
+	if info.InviteHash == "" {
+		return respErr(CodeLinkInvalid, "not an invite link")
+	}
+	ch, err := b.client.JoinInvite(a.ctx, info.InviteHash)
+	if err != nil {
+		return respError(err)
+	}
+	return Response{Code: CodeOK, Details: ch.ChannelID}
+}
+
+// QueueDownloadAfter is QueueDownload with dependencies: the new task
+// only starts once every task in deps has finished successfully, so
+// multi-step workflows (parts before a join step, subtitles after the
+// video) run in order without the frontend polling.
+func (a *App) QueueDownloadAfter(link, destDir, account string, priority int, deps []string) Response {
+	b := a.bundleFor(account)
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	info, err := ParseLink(link)
+	if err != nil {
+		return respError(err)
+	}
+	if info.InviteHash != "" {
+		return respErr(CodeLinkInvalid, "invite link; join the channel first via JoinChannel")
+	}
+	task := a.newTask(info, destDir, b.name)
+	task.DependsOn = deps
+	a.queue.EnqueueAfter(task, priority, deps, func(ctx context.Context) error {
+		return b.downloader.Run(ctx, task)
+	})
+	return respID(task.ID)
+}
+
+// SetFilenameTemplate sets the output naming template applied to new
+// tasks, e.g. "{channel}/{date}_{msg_id}_{filename}". Empty restores the
+// raw filename. Unknown variables are rejected.
+func (a *App) SetFilenameTemplate(tpl string) Response {
+	if err := validateTemplate(tpl); err != nil {
+		return respError(err)
+	}
+	a.mu.Lock()
+	a.nameTemplate = tpl
+	a.mu.Unlock()
+	return respOK()
+}
+
+// SetQualityPreference sets the global video quality preference applied
+// when a message offers multiple qualities: "highest", "lowest", "720p"
+// (closest) or "" for the primary version.
+func (a *App) SetQualityPreference(pref string) Response {
+	switch pref {
+	case "", "highest", "lowest", "720p":
+	default:
+		return respErr(CodeInvalidArgument, "unknown quality preference "+pref)
+	}
+	a.mu.Lock()
+	a.quality = pref
+	a.mu.Unlock()
+	return respOK()
+}
+
+// probeScope returns the context metadata probes run under. One scope
+// covers every in-flight probe, so CancelProbes stops them all at once —
+// e.g. when the user scrolls away from a gallery mid-probe.
+func (a *App) probeScope() context.Context {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.probeCtx == nil || a.probeCtx.Err() != nil {
+		a.probeCtx, a.probeCancel = context.WithCancel(context.Background())
+	}
+	return a.probeCtx
+}
+
+// CancelProbes aborts every in-flight metadata probe; the next probe
+// starts a fresh scope. Downloads are unaffected.
+func (a *App) CancelProbes() Response {
+	a.mu.Lock()
+	cancel := a.probeCancel
+	a.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	return respOK()
+}
+
+// ProbeLink fetches a link's media metadata, including available quality
+// variants, without downloading anything.
+func (a *App) ProbeLink(link string) *ProbeResult {
+	b := a.active()
+	if b == nil {
+		return nil
+	}
+	info, err := ParseLink(link)
+	if err != nil {
+		a.emit("probe:error", err.Error())
+		return nil
+	}
+	result, err := b.downloader.Probe(a.probeScope(), info)
+	if err != nil {
+		a.emit("probe:error", err.Error())
+		return nil
+	}
+	return result
+}
+
+// ProbeMessages batch-probes many message IDs of the link's channel in as
+// few round trips as possible, for the gallery view.
+func (a *App) ProbeMessages(link string, ids []int) []*ProbeResult {
+	b := a.active()
+	if b == nil {
+		return nil
+	}
+	info, err := ParseLink(link)
+	if err != nil {
+		a.emit("probe:error", err.Error())
+		return nil
+	}
+	results, err := b.downloader.ProbeMany(a.probeScope(), info, ids)
+	if err != nil {
+		a.emit("probe:error", err.Error())
+	}
+	return results
+}
+
+// StartDownloadVariant queues a download of a specific media variant
+// reported by ProbeLink.
+func (a *App) StartDownloadVariant(link, destDir string, variant int) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	info, err := ParseLink(link)
+	if err != nil {
+		return respError(err)
+	}
+	task := a.newTask(info, destDir, b.name)
+	task.Variant = variant
+	a.queue.Enqueue(task, 0, func(ctx context.Context) error {
+		return b.downloader.Run(ctx, task)
+	})
+	return respID(task.ID)
+}
+
+// GetAnalytics aggregates the transfer history: average speed per hour of
+// day, failure rate per channel and the most common error classes.
+func (a *App) GetAnalytics() Analytics {
+	return a.history.Analytics()
+}
+
+// GetTransferHistory returns the newest limit transfer records, oldest
+// first; limit <= 0 means all of them.
+func (a *App) GetTransferHistory(limit int) []TransferRecord {
+	return a.history.Recent(limit)
+}
+
+// GetQueueState returns queued, active and finished tasks for the UI.
+func (a *App) GetQueueState() QueueState {
+	return a.queue.State()
+}
+
+// SetQueueConcurrency changes how many downloads run at once.
+func (a *App) SetQueueConcurrency(n int) Response {
+	a.queue.SetMaxConcurrent(n)
+	return respOK()
+}
+
+// SetSmallFileConcurrency changes how many small files download at once
+// in the small-file lane, independent of the big-file cap.
+func (a *App) SetSmallFileConcurrency(n int) Response {
+	a.queue.SetSmallMaxConcurrent(n)
+	return respOK()
+}
+
+// SetTaskPriority re-prioritises a task still waiting in the queue.
+func (a *App) SetTaskPriority(id string, priority int) Response {
+	if !a.queue.SetPriority(id, priority) {
+		return respErr(CodeConflict, "task is not queued")
+	}
+	return respOK()
+}
+
+// DownloadWallpapers saves the account's wallpaper gallery into destDir.
+func (a *App) DownloadWallpapers(destDir string) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	n, err := b.downloader.DownloadWallpapers(context.Background(), destDir)
+	if err != nil {
+		return respError(err)
+	}
+	return Response{Code: CodeOK, Details: n}
+}
+
+// DownloadWallpaperLink saves the wallpaper behind a t.me/bg/<slug> link.
+func (a *App) DownloadWallpaperLink(link, destDir string) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	name, err := b.downloader.DownloadWallpaperLink(context.Background(), link, destDir)
+	if err != nil {
+		return respError(err)
+	}
+	return respID(name)
+}
+
+// DownloadThemeLink saves the theme file behind a t.me/addtheme/<slug> link.
+func (a *App) DownloadThemeLink(link, destDir string) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	name, err := b.downloader.DownloadThemeLink(context.Background(), link, destDir)
+	if err != nil {
+		return respError(err)
+	}
+	return respID(name)
+}
+
+// downloaderFor returns the downloader owning a task, falling back to the
+// active account's.
+func (a *App) downloaderFor(task *DownloadTask) *Downloader {
+	b := a.bundleFor(task.Account)
+	if b == nil {
+		return nil
+	}
+	return b.downloader
+}
+
+// CancelDownload aborts a running task by ID. The cancelled task stays in
+// the registry, so the UI keeps showing it until DiscardTask or
+// ClearCompletedTasks removes it.
+func (a *App) CancelDownload(id string) Response {
+	task, ok := a.tasks.Get(id)
+	if !ok {
+		return respErr(CodeNotFound, "no such task "+id)
+	}
+	a.audit.Record("cancel", id)
+	if a.queue.Remove(id) {
+		task.setStatus("cancelled")
+		return respOK()
+	}
+	task.Cancel()
+	return respOK()
+}
+
+// CancelPostProcessing stops a task's post-download verification pass
+// while leaving the transfer and the finished file alone, for when a hash
+// walk over a huge file is holding up the queue.
+func (a *App) CancelPostProcessing(id string) Response {
+	task, ok := a.tasks.Get(id)
+	if !ok {
+		return respErr(CodeNotFound, "no such task "+id)
+	}
+	if !task.CancelPost() {
+		return respErr(CodeConflict, "task has no post-processing running")
+	}
+	a.audit.Record("cancel", id+" post-processing")
+	return respOK()
+}
+
+// PauseDownload pauses a running task, keeping its partial file on disk.
+func (a *App) PauseDownload(id string) Response {
+	task, ok := a.tasks.Get(id)
+	if !ok {
+		return respErr(CodeNotFound, "no such task "+id)
+	}
+	if s := task.status(); s != "running" {
+		return respErr(CodeConflict, "task is "+s)
+	}
+	a.audit.Record("pause", id)
+	task.Pause()
+	return respOK()
+}
+
+// ResumeDownload continues a paused task from the bytes already written.
+func (a *App) ResumeDownload(id string) Response {
+	task, ok := a.tasks.Get(id)
+	if !ok {
+		return respErr(CodeNotFound, "no such task "+id)
+	}
+	if s := task.status(); s != "paused" {
+		return respErr(CodeConflict, "task is "+s)
+	}
+	dl := a.downloaderFor(task)
+	if dl == nil {
+		return respErr(CodeNotFound, "task account is gone")
+	}
+	a.audit.Record("resume", id)
+	go dl.Resume(context.Background(), task)
+	return respOK()
+}
+
+// SetGlobalSpeedLimit caps the combined speed of all downloads across all
+// accounts, in bytes per second. Zero removes the cap.
+func (a *App) SetGlobalSpeedLimit(bytesPerSec int64) Response {
+	if bytesPerSec < 0 {
+		return respErr(CodeInvalidArgument, "limit must not be negative")
+	}
+	a.audit.Record("settings", fmt.Sprintf("global speed limit %d", bytesPerSec))
+	a.globalLimit.SetRate(bytesPerSec)
+	return respOK()
+}
+
+// SetTaskSpeedLimit caps a single task's speed in bytes per second, on top
+// of the global limit. Zero removes the per-task cap.
+func (a *App) SetTaskSpeedLimit(id string, bytesPerSec int64) Response {
+	if bytesPerSec < 0 {
+		return respErr(CodeInvalidArgument, "limit must not be negative")
+	}
+	task, ok := a.tasks.Get(id)
+	if !ok {
+		return respErr(CodeNotFound, "no such task "+id)
+	}
+	task.SetSpeedLimit(bytesPerSec)
+	return respOK()
+}
+
+// SetConnectionPool sets how many MTProto connections chunk downloads fan
+// out across per account; 0 or 1 keeps the single connection. The pool is
+// shared by all tasks on an account, so the cap bounds total connections,
+// not per-task ones.
+func (a *App) SetConnectionPool(size int) Response {
+	if size < 0 || size > 16 {
+		return respErr(CodeInvalidArgument, "pool size must be between 0 and 16")
+	}
+	a.mu.Lock()
+	a.poolSize = size
+	for _, b := range a.bundles {
+		b.client.SetPoolSize(size)
+	}
+	a.mu.Unlock()
+	a.audit.Record("settings", fmt.Sprintf("connection pool %d", size))
+	return respOK()
+}
+
+// SetZeroBytePolicy picks how zero-byte files are handled: "" downloads
+// them like any other file, "skip" finishes the task without touching
+// disk, "flag" downloads them but marks the task suspicious so batch
+// reports call it out.
+func (a *App) SetZeroBytePolicy(policy string) Response {
+	switch policy {
+	case zeroByteDownload, zeroByteSkip, zeroByteFlag:
+	default:
+		return respErr(CodeInvalidArgument, fmt.Sprintf("unknown zero-byte policy %q", policy))
+	}
+	a.mu.Lock()
+	a.zeroByte = policy
+	for _, b := range a.bundles {
+		b.downloader.SetZeroBytePolicy(policy)
+	}
+	a.mu.Unlock()
+	a.audit.Record("settings", fmt.Sprintf("zero-byte policy %q", policy))
+	return respOK()
+}
+
+// SetDownloadTuning sets the default worker count and chunk size for new
+// downloads. Zero keeps the built-in choice for that knob: a fixed worker
+// count and a chunk size picked from the file size. Threads go up to 64
+// for fast links; chunk sizes must be 4 KiB–1 MiB values Telegram
+// accepts (multiples of 4 KiB dividing 1 MiB).
+func (a *App) SetDownloadTuning(threads, partSize int) Response {
+	if threads < 0 || threads > maxThreads {
+		return respErr(CodeInvalidArgument, fmt.Sprintf("threads must be between 0 and %d", maxThreads))
+	}
+	if partSize != 0 && !validPartSize(partSize) {
+		return respErr(CodeInvalidArgument, "part size must be a multiple of 4096 dividing 1048576")
+	}
+	a.mu.Lock()
+	a.threads = threads
+	a.partSize = partSize
+	a.mu.Unlock()
+	a.audit.Record("settings", fmt.Sprintf("download tuning threads=%d partSize=%d", threads, partSize))
+	return respOK()
+}
+
+// StartDownloadTuned is StartDownload with per-task worker count and
+// chunk size overrides, for one-off transfers that want more (or less)
+// parallelism than the global tuning. Zero keeps the global value for
+// that knob.
+func (a *App) StartDownloadTuned(link, destDir string, threads, partSize int) Response {
+	if threads < 0 || threads > maxThreads {
+		return respErr(CodeInvalidArgument, fmt.Sprintf("threads must be between 0 and %d", maxThreads))
+	}
+	if partSize != 0 && !validPartSize(partSize) {
+		return respErr(CodeInvalidArgument, "part size must be a multiple of 4096 dividing 1048576")
+	}
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	info, err := ParseLink(link)
+	if err != nil {
+		return respError(err)
+	}
+	if info.InviteHash != "" {
+		return respErr(CodeLinkInvalid, "invite link; join the channel first via JoinChannel")
+	}
+	task := a.newTask(info, destDir, b.name)
+	if threads > 0 {
+		task.Threads = threads
+	}
+	if partSize > 0 {
+		task.PartSize = partSize
+	}
+	a.queue.Enqueue(task, 0, func(ctx context.Context) error {
+		return b.downloader.Run(ctx, task)
+	})
+	a.audit.Record("queue", fmt.Sprintf("%s %s threads=%d partSize=%d", task.ID, link, task.Threads, task.PartSize))
+	return respID(task.ID)
+}
+
+// SetTaskStrategy picks the range-fetch order for a task: "sequential"
+// (the default), "random" or "rarest". It takes effect when the task next
+// starts or resumes; a transfer already in flight keeps its order.
+func (a *App) SetTaskStrategy(id, strategy string) Response {
+	switch strategy {
+	case "", strategySequential, strategyRandom, strategyRarest:
+	default:
+		return respErr(CodeInvalidArgument, "unknown strategy "+strategy)
+	}
+	task, ok := a.tasks.Get(id)
+	if !ok {
+		return respErr(CodeNotFound, "no such task "+id)
+	}
+	task.setStrategy(strategy)
+	return respOK()
+}
+
+// EnableTakeout requests a data-export session for the active account.
+// Telegram may defer the request so the user can confirm it in an official
+// client; the pending request is persisted and retried automatically once
+// the delay has passed.
+func (a *App) EnableTakeout() Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	st, err := b.client.InitTakeout(a.ctx)
+	if err != nil {
+		return respError(err)
+	}
+	if st.Pending {
+		return Response{
+			Code:    CodePending,
+			Message: "confirm the export in an official Telegram app",
+			Details: st.RetryAt,
+		}
+	}
+	return Response{Code: CodeOK, Details: st.SessionID}
+}
+
+// SetUseTakeout routes whole-channel scrapes and batch history walks
+// through the takeout session, which Telegram rate-limits far more
+// generously. A no-op for accounts whose session was not granted yet, so
+// it is safe to turn on before EnableTakeout's consent flow completes.
+func (a *App) SetUseTakeout(on bool) Response {
+	a.mu.Lock()
+	a.useTakeout = on
+	for _, b := range a.bundles {
+		b.exporter.SetUseTakeout(on)
+	}
+	a.mu.Unlock()
+	a.audit.Record("settings", fmt.Sprintf("use takeout %v", on))
+	return respOK()
+}
+
+// GetTakeoutState returns the active account's takeout consent state.
+func (a *App) GetTakeoutState() TakeoutState {
+	b := a.active()
+	if b == nil {
+		return TakeoutState{}
+	}
+	return b.client.TakeoutStatus()
+}
+
+// SetBandwidthSchedule replaces the time-of-day bandwidth rules, persists
+// them and applies the window active right now. While the schedule is
+// enabled it owns the global speed limit.
+func (a *App) SetBandwidthSchedule(schedule BandwidthSchedule) Response {
+	if err := a.scheduler.Set(schedule); err != nil {
+		return respError(err)
+	}
+	return respOK()
+}
+
+// GetBandwidthSchedule returns the configured time-of-day bandwidth rules.
+func (a *App) GetBandwidthSchedule() BandwidthSchedule {
+	return a.scheduler.Get()
+}
+
+// SetOnlineStatus controls whether the active account appears online to
+// its contacts while TeleTurbo runs. The default after connecting is
+// offline/invisible.
+func (a *App) SetOnlineStatus(online bool) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	if err := b.client.SetPresence(a.ctx, online); err != nil {
+		return respError(err)
+	}
+	return respOK()
+}
+
+// SetTaskDeadline gives a task a completion deadline in RFC 3339 form;
+// past it the task is paused and deadline_missed is emitted. An empty
+// deadline clears it.
+func (a *App) SetTaskDeadline(id, deadline string) Response {
+	task, ok := a.tasks.Get(id)
+	if !ok {
+		return respErr(CodeNotFound, "no such task "+id)
+	}
+	if deadline == "" {
+		task.setDeadline(time.Time{})
+		return respOK()
+	}
+	t, err := time.Parse(time.RFC3339, deadline)
+	if err != nil {
+		return respError(err)
+	}
+	task.setDeadline(t)
+	return respOK()
+}
+
+// SetVerifyDownloads toggles post-download hash verification for all
+// accounts and future downloads.
+func (a *App) SetVerifyDownloads(on bool) Response {
+	a.mu.Lock()
+	a.verifyAll = on
+	for _, b := range a.bundles {
+		b.downloader.SetVerifyAll(on)
+	}
+	a.mu.Unlock()
+	return respOK()
+}
+
+// SetTaskVerify requests (or clears) hash verification for one task,
+// regardless of the global toggle. It must be set before the task
+// finishes to have an effect.
+func (a *App) SetTaskVerify(id string, on bool) Response {
+	task, ok := a.tasks.Get(id)
+	if !ok {
+		return respErr(CodeNotFound, "no such task "+id)
+	}
+	task.setVerify(on)
+	return respOK()
+}
+
+// SetProgressPolicy tunes how often progress events are delivered on one
+// channel ("ui", "webhook" or "rpc"): at most every intervalMs, unless
+// progress advanced by minPercent since the last event.
+func (a *App) SetProgressPolicy(channel string, intervalMs int, minPercent float64) Response {
+	if !progressChannels[channel] {
+		return respErr(CodeInvalidArgument, "unknown delivery channel "+channel)
+	}
+	if intervalMs < 0 || minPercent < 0 {
+		return respErr(CodeInvalidArgument, "interval and percent must not be negative")
+	}
+	a.progress.SetPolicy(channel, ProgressPolicy{IntervalMs: intervalMs, MinPercent: minPercent})
+	return respOK()
+}
+
+// SetBackgroundSpeedLimit caps the combined speed of downloads the user is
+// not currently viewing, in bytes per second. Zero removes the cap.
+func (a *App) SetBackgroundSpeedLimit(bytesPerSec int64) Response {
+	if bytesPerSec < 0 {
+		return respErr(CodeInvalidArgument, "limit must not be negative")
+	}
+	a.audit.Record("settings", fmt.Sprintf("background speed limit %d", bytesPerSec))
+	a.bgLimit.SetRate(bytesPerSec)
+	return respOK()
+}
+
+// SetFocusedTask marks the task whose detail view the user is looking at;
+// that task runs at full speed while the rest share the background limit.
+// Pass "" when no task is focused.
+func (a *App) SetFocusedTask(id string) Response {
+	a.mu.Lock()
+	a.focusedTask = id
+	a.mu.Unlock()
+	return respOK()
+}
+
+// isTaskFocused reports whether a task is exempt from the background limit.
+func (a *App) isTaskFocused(id string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.focusedTask != "" && a.focusedTask == id
+}
+
+// GetTask returns the current state of a task for UI polling.
+func (a *App) GetTask(id string) *DownloadTask {
+	task, _ := a.tasks.Get(id)
+	return task
+}
+
+// ListIncompleteTasks returns tasks that are not done, including ones
+// restored from a previous run, so the UI can offer resume or discard.
+func (a *App) ListIncompleteTasks() []*DownloadTask {
+	var out []*DownloadTask
+	for _, task := range a.tasks.List() {
+		if task.status() != "done" {
+			out = append(out, task)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return taskNumber(out[i].ID) < taskNumber(out[j].ID) })
+	return out
+}
+
+// DiscardTask forgets a task and, when it never finished, removes its
+// partial file from disk.
+func (a *App) DiscardTask(id string) Response {
+	task, ok := a.tasks.Remove(id)
+	if !ok {
+		return respErr(CodeNotFound, "no such task "+id)
+	}
+	a.audit.Record("discard", id)
+	if task.status() == "running" {
+		task.Cancel()
+	}
+	if name, _ := task.fileMeta(); task.status() != "done" && name != "" {
+		path := filepath.Join(task.DestDir, name) + partSuffix
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return respError(err)
+		}
+	}
+	return respOK()
+}
+
+// ClearCompletedTasks forgets every task that is done, failed or
+// cancelled, leaving files on disk untouched.
+func (a *App) ClearCompletedTasks() Response {
+	n := a.tasks.ClearCompleted()
+	return Response{Code: CodeOK, Details: n}
+}
+
+// SetWatchRules replaces the active account's watch-mode rules.
+func (a *App) SetWatchRules(rules []WatchRule) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	a.audit.Record("settings", fmt.Sprintf("%d watch rules", len(rules)))
+	b.watcher.SetRules(rules)
+	go b.watcher.WarmCache(context.Background(), b.client, nil)
+	return respOK()
+}
+
+// AddWatchRule subscribes the active account to one channel without
+// touching the existing subscriptions.
+func (a *App) AddWatchRule(rule WatchRule) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	if rule.ChannelID == 0 && rule.Username == "" {
+		return respErr(CodeInvalidArgument, "rule needs a channel ID or username")
+	}
+	a.audit.Record("settings", fmt.Sprintf("watch rule added for %s/%d", rule.Username, rule.ChannelID))
+	b.watcher.AddRule(rule)
+	go b.watcher.WarmCache(context.Background(), b.client, nil)
+	return respOK()
+}
+
+// RemoveWatchRules unsubscribes the active account from a channel;
+// Details carries how many rules were dropped.
+func (a *App) RemoveWatchRules(channelID int64) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	n := b.watcher.RemoveRules(channelID)
+	if n == 0 {
+		return respErr(CodeNotFound, fmt.Sprintf("no rules watch channel %d", channelID))
+	}
+	a.audit.Record("settings", fmt.Sprintf("%d watch rules removed for %d", n, channelID))
+	return Response{Code: CodeOK, Details: n}
+}
+
+// GetWatchRules lists the active account's subscriptions.
+func (a *App) GetWatchRules() []WatchRule {
+	b := a.active()
+	if b == nil {
+		return nil
+	}
+	return b.watcher.Rules()
+}
+
+// ResumeWatchRule clears the paused flag on a channel's rules, e.g. after
+// the user freed up disk space.
+func (a *App) ResumeWatchRule(channelID int64) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	b.watcher.ResumeRule(channelID)
+	return respOK()
+}
+
+// SetWatchWorkers resizes the active account's watch processing pool.
+func (a *App) SetWatchWorkers(n int) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	b.watcher.SetWorkers(n)
+	return respOK()
+}
+
+// SetRecorderCommand configures the external recorder invoked when a live
+// stream starts in a watched channel. Empty disables the hand-off.
+func (a *App) SetRecorderCommand(command string) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	b.watcher.SetRecorderCommand(command)
+	return respOK()
+}
+
+// GetWatchMetrics returns a snapshot of the watch worker pool for the UI.
+func (a *App) GetWatchMetrics() WatchMetrics {
+	b := a.active()
+	if b == nil {
+		return WatchMetrics{}
+	}
+	return b.watcher.Metrics()
+}
+
+// SyncChannel mirrors a channel into destDir and returns the diff against
+// the previous sync of the same directory. A zero policy means unbounded.
+func (a *App) SyncChannel(link, destDir string, policy RetentionPolicy) *ExportDiff {
+	b := a.active()
+	if b == nil {
+		return nil
+	}
+	info, err := ParseLink(link)
+	if err != nil {
+		a.emit("export:error", err.Error())
+		return nil
+	}
+	diff, _, err := b.exporter.Sync(context.Background(), info, destDir, policy, failSkip, nil)
+	if err != nil {
+		a.emit("export:error", err.Error())
+		return nil
+	}
+	return diff
+}
+
+// ExportCustomEmoji downloads the custom emoji packs used in a channel
+// into destDir along with an emoji manifest.
+func (a *App) ExportCustomEmoji(link, destDir string) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	info, err := ParseLink(link)
+	if err != nil {
+		return respError(err)
+	}
+	n, err := b.exporter.ExportCustomEmoji(context.Background(), info, destDir)
+	if err != nil {
+		return respError(err)
+	}
+	return Response{Code: CodeOK, Details: n}
+}
+
+// LastExportDiff returns the newest saved diff report for an export
+// directory, so curators can review channel changes after the fact.
+func (a *App) LastExportDiff(destDir string) *ExportDiff {
+	diff, err := LastDiff(destDir)
+	if err != nil {
+		a.emit("export:error", err.Error())
+		return nil
+	}
+	return diff
+}
+
+func (a *App) newTask(link *LinkInfo, destDir, account string) *DownloadTask {
+	if destDir == "" {
+		destDir = a.destDir
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.nextID++
+	task := &DownloadTask{
+		ID:           fmt.Sprintf("task-%d", a.nextID),
+		Link:         link,
+		DestDir:      destDir,
+		Account:      account,
+		Quality:      a.quality,
+		NameTemplate: a.nameTemplate,
+		Threads:      a.threads,
+		PartSize:     a.partSize,
+		Status:       "queued",
+		limit:        newRateLimiter(0),
+	}
+	a.tasks.Add(task)
+	return task
+}
@@ -0,0 +1,177 @@
+//go:build headless
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The /jsonrpc endpoint speaks the aria2c RPC dialect, so download-manager
+// frontends and browser extensions that already drive aria2 can drive
+// Telegram downloads unchanged. Task IDs stand in for aria2 GIDs; the
+// secret goes in the usual "token:<secret>" first parameter or in the
+// regular API token headers.
+
+type rpcRequest struct {
+	ID     interface{}       `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      interface{} `json:"id"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   *rpcError   `json:"error,omitempty"`
+}
+
+func (s *apiServer) handleJSONRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req rpcRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusOK, rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: -32700, Message: err.Error()}})
+		return
+	}
+
+	// aria2 clients pass the secret as a "token:<secret>" first param.
+	token := requestToken(r)
+	params := req.Params
+	if len(params) > 0 {
+		var first string
+		if json.Unmarshal(params[0], &first) == nil && strings.HasPrefix(first, "token:") {
+			token = strings.TrimPrefix(first, "token:")
+			params = params[1:]
+		}
+	}
+	app := s.appForToken(token)
+	if app == nil {
+		writeJSON(w, http.StatusOK, rpcResponse{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: 1, Message: "unauthorized"}})
+		return
+	}
+
+	result, rerr := s.dispatchRPC(app, req.Method, params)
+	writeJSON(w, http.StatusOK, rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rerr})
+}
+
+func (s *apiServer) dispatchRPC(app *App, method string, params []json.RawMessage) (interface{}, *rpcError) {
+	gid := func() (string, bool) {
+		var id string
+		if len(params) < 1 || json.Unmarshal(params[0], &id) != nil {
+			return "", false
+		}
+		return id, true
+	}
+	switch method {
+	case "aria2.addUri":
+		var uris []string
+		if len(params) < 1 || json.Unmarshal(params[0], &uris) != nil || len(uris) == 0 {
+			return nil, &rpcError{Code: 1, Message: "addUri needs a uris array"}
+		}
+		var opts map[string]string
+		if len(params) > 1 {
+			json.Unmarshal(params[1], &opts)
+		}
+		res := app.StartDownload(uris[0], opts["dir"])
+		if !res.OK() {
+			return nil, &rpcError{Code: 1, Message: res.Message}
+		}
+		id, _ := res.Details.(string)
+		return id, nil
+	case "aria2.tellStatus":
+		id, ok := gid()
+		if !ok {
+			return nil, &rpcError{Code: 1, Message: "tellStatus needs a gid"}
+		}
+		task, ok := app.tasks.Get(id)
+		if !ok {
+			return nil, &rpcError{Code: 1, Message: "GID " + id + " is not found"}
+		}
+		return aria2Status(task), nil
+	case "aria2.pause", "aria2.forcePause":
+		id, ok := gid()
+		if !ok {
+			return nil, &rpcError{Code: 1, Message: "pause needs a gid"}
+		}
+		if res := app.PauseDownload(id); !res.OK() {
+			return nil, &rpcError{Code: 1, Message: res.Message}
+		}
+		return id, nil
+	case "aria2.unpause":
+		id, ok := gid()
+		if !ok {
+			return nil, &rpcError{Code: 1, Message: "unpause needs a gid"}
+		}
+		if res := app.ResumeDownload(id); !res.OK() {
+			return nil, &rpcError{Code: 1, Message: res.Message}
+		}
+		return id, nil
+	case "aria2.remove", "aria2.forceRemove":
+		id, ok := gid()
+		if !ok {
+			return nil, &rpcError{Code: 1, Message: "remove needs a gid"}
+		}
+		if res := app.CancelDownload(id); !res.OK() {
+			return nil, &rpcError{Code: 1, Message: res.Message}
+		}
+		return id, nil
+	case "aria2.getVersion":
+		return map[string]interface{}{
+			"version":         appVersion,
+			"enabledFeatures": []string{},
+		}, nil
+	default:
+		return nil, &rpcError{Code: -32601, Message: "method " + method + " is not supported"}
+	}
+}
+
+// aria2Status renders a task in the shape aria2.tellStatus clients
+// expect; aria2 reports every number as a decimal string.
+func aria2Status(task *DownloadTask) map[string]interface{} {
+	taskStatus := task.status()
+	status := "waiting"
+	switch taskStatus {
+	case "running":
+		status = "active"
+	case "paused":
+		status = "paused"
+	case "done":
+		status = "complete"
+	case "failed", "expired":
+		status = "error"
+	case "cancelled":
+		status = "removed"
+	}
+	written := atomic.LoadInt64(&task.Written)
+	var speed int64
+	if started := task.started(); taskStatus == "running" && !started.IsZero() {
+		if secs := time.Since(started).Seconds(); secs > 0 {
+			speed = int64(float64(written) / secs)
+		}
+	}
+	name, size := task.fileMeta()
+	return map[string]interface{}{
+		"gid":             task.ID,
+		"status":          status,
+		"totalLength":     strconv.FormatInt(size, 10),
+		"completedLength": strconv.FormatInt(written, 10),
+		"downloadSpeed":   strconv.FormatInt(speed, 10),
+		"errorMessage":    task.errText(),
+		"files": []map[string]string{
+			{"path": filepath.Join(task.DestDir, name)},
+		},
+	}
+}
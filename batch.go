@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// BatchLineError reports one rejected line from a pasted link list.
+type BatchLineError struct {
+	Line  int    `json:"line"`
+	Link  string `json:"link"`
+	Error string `json:"error"`
+}
+
+// Batch groups the tasks created from one pasted list of links.
+type Batch struct {
+	ID      string           `json:"id"`
+	TaskIDs []string         `json:"taskIds"`
+	Errors  []BatchLineError `json:"errors"`
+}
+
+// BatchProgress is the aggregate state of a batch for UI polling.
+type BatchProgress struct {
+	ID        string `json:"id"`
+	Total     int    `json:"total"`
+	Done      int    `json:"done"`
+	Failed    int    `json:"failed"`
+	Cancelled int    `json:"cancelled"`
+	// Suspicious counts zero-byte files flagged by the zero-byte policy.
+	Suspicious int              `json:"suspicious"`
+	Written    int64            `json:"written"`
+	Size       int64            `json:"size"`
+	Errors     []BatchLineError `json:"errors"`
+}
+
+// StartBatchDownload parses many t.me links at once and queues a task for
+// each valid one. Invalid lines are skipped and reported per line; blank
+// lines are ignored. Returns the batch ID, or an error when no line was
+// usable.
+func (a *App) StartBatchDownload(links []string, destDir string) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+
+	a.mu.Lock()
+	a.nextBatchID++
+	batch := &Batch{ID: fmt.Sprintf("batch-%d", a.nextBatchID)}
+	a.mu.Unlock()
+
+	for i, raw := range links {
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+		info, err := ParseLink(line)
+		if err != nil {
+			batch.Errors = append(batch.Errors, BatchLineError{Line: i + 1, Link: line, Error: err.Error()})
+			continue
+		}
+		task := a.newTask(info, destDir, b.name)
+		a.queue.Enqueue(task, 0, func(ctx context.Context) error {
+			return b.downloader.Run(ctx, task)
+		})
+		batch.TaskIDs = append(batch.TaskIDs, task.ID)
+	}
+	if len(batch.TaskIDs) == 0 {
+		return respErr(CodeInvalidArgument, fmt.Sprintf("no valid links in %d lines", len(links)))
+	}
+
+	a.mu.Lock()
+	a.batches[batch.ID] = batch
+	a.mu.Unlock()
+	return respID(batch.ID)
+}
+
+// CancelBatchRemaining cancels a batch's tasks that have not started yet,
+// letting transfers already in flight finish. The number of tasks removed
+// rides in Details.
+func (a *App) CancelBatchRemaining(id string) Response {
+	a.mu.Lock()
+	batch, ok := a.batches[id]
+	a.mu.Unlock()
+	if !ok {
+		return respErr(CodeNotFound, "no such batch "+id)
+	}
+	removed := 0
+	for _, taskID := range batch.TaskIDs {
+		task, ok := a.tasks.Get(taskID)
+		if !ok || task.status() != "queued" {
+			continue
+		}
+		if a.queue.Remove(taskID) {
+			task.setStatus("cancelled")
+			removed++
+		}
+	}
+	a.audit.Record("cancel", fmt.Sprintf("%s remaining (%d tasks)", id, removed))
+	return Response{Code: CodeOK, Details: removed}
+}
+
+// GetBatchProgress aggregates the state of all tasks in a batch.
+func (a *App) GetBatchProgress(id string) *BatchProgress {
+	a.mu.Lock()
+	batch, ok := a.batches[id]
+	if !ok {
+		a.mu.Unlock()
+		return nil
+	}
+	tasks := make([]*DownloadTask, 0, len(batch.TaskIDs))
+	for _, taskID := range batch.TaskIDs {
+		if task, ok := a.tasks.Get(taskID); ok {
+			tasks = append(tasks, task)
+		}
+	}
+	a.mu.Unlock()
+
+	progress := &BatchProgress{ID: batch.ID, Total: len(tasks), Errors: batch.Errors}
+	for _, task := range tasks {
+		progress.Written += atomic.LoadInt64(&task.Written)
+		_, size := task.fileMeta()
+		progress.Size += size
+		if task.suspicious() {
+			progress.Suspicious++
+		}
+		switch task.status() {
+		case "done":
+			progress.Done++
+		case "failed":
+			progress.Failed++
+		case "cancelled":
+			progress.Cancelled++
+		}
+	}
+	return progress
+}
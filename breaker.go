@@ -0,0 +1,82 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// breakerThreshold is how many consecutive failures open a channel's
+	// circuit.
+	breakerThreshold = 5
+	// breakerCooldown is how long an open circuit blocks the channel
+	// before one retry is allowed through again.
+	breakerCooldown = 10 * time.Minute
+)
+
+// channelBreaker is a per-channel circuit breaker: a channel that keeps
+// failing (ban, privacy change, revoked access) is paused as a whole and
+// announced once, instead of every task hammering the API and flooding
+// the log with the same error.
+type channelBreaker struct {
+	mu        sync.Mutex
+	failures  map[int64]int
+	openUntil map[int64]time.Time
+	emit      func(event string, data interface{})
+}
+
+func newChannelBreaker(emit func(event string, data interface{})) *channelBreaker {
+	return &channelBreaker{
+		failures:  make(map[int64]int),
+		openUntil: make(map[int64]time.Time),
+		emit:      emit,
+	}
+}
+
+// Allow reports whether work against the channel may proceed. After the
+// cooldown one attempt is let through to probe recovery.
+func (b *channelBreaker) Allow(channelID int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until, open := b.openUntil[channelID]
+	if !open {
+		return true
+	}
+	if time.Now().After(until) {
+		// Half-open: allow one probe; the next failure re-opens.
+		delete(b.openUntil, channelID)
+		b.failures[channelID] = breakerThreshold - 1
+		return true
+	}
+	return false
+}
+
+// Success resets the channel's failure count.
+func (b *channelBreaker) Success(channelID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.failures, channelID)
+	delete(b.openUntil, channelID)
+}
+
+// Failure counts one error and opens the circuit at the threshold,
+// emitting a single actionable alert.
+func (b *channelBreaker) Failure(channelID int64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures[channelID]++
+	if b.failures[channelID] < breakerThreshold {
+		return
+	}
+	if _, alreadyOpen := b.openUntil[channelID]; alreadyOpen {
+		return
+	}
+	b.openUntil[channelID] = time.Now().Add(breakerCooldown)
+	b.emit("channel:circuit-open", map[string]interface{}{
+		"channelId": channelID,
+		"error":     err.Error(),
+		"retryAt":   time.Now().Add(breakerCooldown),
+	})
+	logWarnf("breaker: channel %d paused for %s after %d consecutive failures: %v",
+		channelID, breakerCooldown, breakerThreshold, err)
+}
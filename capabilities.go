@@ -0,0 +1,36 @@
+package main
+
+// Capabilities is the machine-readable manifest of what the running build
+// can do, so frontends and remote clients show only the controls that
+// will work instead of hardcoding per-build knowledge.
+type Capabilities struct {
+	Version string `json:"version"`
+	// Build is the entry-point variant: "desktop" or "headless".
+	Build string `json:"build"`
+	// Features maps stable feature names to availability. Unknown names
+	// must be treated as absent, so new features can be added without
+	// breaking old clients.
+	Features map[string]bool `json:"features"`
+}
+
+// GetCapabilities reports the build's version, variant and optional
+// subsystems.
+func (a *App) GetCapabilities() Capabilities {
+	headless := buildVariant == "headless"
+	return Capabilities{
+		Version: appVersion,
+		Build:   buildVariant,
+		Features: map[string]bool{
+			"multiAccount":   true,
+			"streaming":      true, // loopback media streaming, stream.go
+			"takeout":        true, // account.initTakeoutSession exports
+			"cdn":            true, // CDN DC redirect handling
+			"connectionPool": true, // multi-connection chunk fetching
+			"restApi":        headless,
+			"aria2Rpc":       headless, // aria2-compatible /jsonrpc shim
+			"webUi":          headless,
+			"events":         headless, // SSE and WebSocket event streams
+			"cli":            headless,
+		},
+	}
+}
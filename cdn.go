@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+)
+
+// cdnBlockSize is the hash granularity Telegram uses for CDN files: the
+// SHA-256 of every 128 KiB block must match the hash the master DC signs
+// off on before the bytes may be written, since CDN DCs are third-party
+// caches that are not trusted with plaintext or integrity.
+const cdnBlockSize = 128 * 1024
+
+// cdnSession is one file's connection to a CDN DC: popular large files
+// get served from these caches via an upload.fileCdnRedirect instead of
+// the usual upload.file. The payload arrives AES-256-CTR encrypted with
+// the key and IV from the redirect and is verified per block against
+// master-provided hashes.
+type cdnSession struct {
+	api   *tg.Client
+	conn  telegram.CloseInvoker
+	token []byte
+	key   []byte
+	iv    []byte
+
+	mu     sync.Mutex
+	hashes map[int64]tg.FileHash // block offset -> expected hash
+}
+
+// cdnSession dials the CDN DC a redirect points at and prepares the
+// decryption and verification state from it.
+func (c *TGClient) cdnSession(ctx context.Context, r *tg.UploadFileCDNRedirect) (*cdnSession, error) {
+	conn, err := c.client.DC(ctx, r.DCID, 1)
+	if err != nil {
+		return nil, fmt.Errorf("connect cdn dc %d: %w", r.DCID, err)
+	}
+	s := &cdnSession{
+		api:    tg.NewClient(conn),
+		conn:   conn,
+		token:  r.FileToken,
+		key:    r.EncryptionKey,
+		iv:     r.EncryptionIv,
+		hashes: make(map[int64]tg.FileHash),
+	}
+	s.addHashes(r.FileHashes)
+	return s, nil
+}
+
+// Close tears down the CDN DC connection.
+func (s *cdnSession) Close() {
+	s.conn.Close()
+}
+
+func (s *cdnSession) addHashes(hashes []tg.FileHash) {
+	s.mu.Lock()
+	for _, h := range hashes {
+		s.hashes[h.Offset] = h
+	}
+	s.mu.Unlock()
+}
+
+// fetch gets one decrypted, hash-verified range from the CDN. master is
+// the regular DC connection, needed for re-upload requests and for
+// fetching hashes of blocks the redirect did not cover.
+func (s *cdnSession) fetch(ctx context.Context, master *tg.Client, offset int64, limit int) ([]byte, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		res, err := s.api.UploadGetCDNFile(ctx, &tg.UploadGetCDNFileRequest{
+			FileToken: s.token,
+			Offset:    offset,
+			Limit:     limit,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cdn get file: %w", err)
+		}
+		switch f := res.(type) {
+		case *tg.UploadCDNFileReuploadNeeded:
+			// The cache dropped the file; ask the master DC to push it
+			// back, which also yields fresh block hashes.
+			hashes, err := master.UploadReuploadCDNFile(ctx, &tg.UploadReuploadCDNFileRequest{
+				FileToken:    s.token,
+				RequestToken: f.RequestToken,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("cdn reupload: %w", err)
+			}
+			s.addHashes(hashes)
+			continue
+		case *tg.UploadCDNFile:
+			data, err := s.decrypt(offset, f.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.verify(ctx, master, offset, data); err != nil {
+				return nil, err
+			}
+			return data, nil
+		default:
+			return nil, fmt.Errorf("unexpected cdn file type %T", res)
+		}
+	}
+	return nil, fmt.Errorf("cdn still asks for reupload after one round trip")
+}
+
+// decrypt applies AES-256-CTR with the counter advanced to the range's
+// position: per the MTProto CDN scheme, the IV's trailing 32 bits carry
+// the block counter, offset/16 ahead of the start.
+func (s *cdnSession) decrypt(offset int64, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, fmt.Errorf("cdn key: %w", err)
+	}
+	iv := make([]byte, len(s.iv))
+	copy(iv, s.iv)
+	ctr := binary.BigEndian.Uint32(iv[12:]) + uint32(offset/16)
+	binary.BigEndian.PutUint32(iv[12:], ctr)
+	out := make([]byte, len(data))
+	cipher.NewCTR(block, iv).XORKeyStream(out, data)
+	return out, nil
+}
+
+// verify checks every hash block the decrypted range covers, fetching
+// hashes the session does not hold yet from the master DC. Chunk offsets
+// are part-aligned, which keeps them block-aligned too.
+func (s *cdnSession) verify(ctx context.Context, master *tg.Client, offset int64, data []byte) error {
+	for b := offset; b < offset+int64(len(data)); b += cdnBlockSize {
+		s.mu.Lock()
+		fh, ok := s.hashes[b]
+		s.mu.Unlock()
+		if !ok {
+			hashes, err := master.UploadGetCDNFileHashes(ctx, &tg.UploadGetCDNFileHashesRequest{
+				FileToken: s.token,
+				Offset:    b,
+			})
+			if err != nil {
+				return fmt.Errorf("cdn hashes at %d: %w", b, err)
+			}
+			s.addHashes(hashes)
+			s.mu.Lock()
+			fh, ok = s.hashes[b]
+			s.mu.Unlock()
+			if !ok {
+				return fmt.Errorf("no hash for cdn block at %d", b)
+			}
+		}
+		end := b - offset + int64(fh.Limit)
+		if end > int64(len(data)) {
+			return fmt.Errorf("cdn block at %d truncated", b)
+		}
+		sum := sha256.Sum256(data[b-offset : end])
+		if !bytes.Equal(sum[:], fh.Hash) {
+			return fmt.Errorf("cdn block hash mismatch at offset %d", b)
+		}
+	}
+	return nil
+}
+
+// fetchCdn serves one chunk through the task's CDN session, opening it on
+// the first redirected chunk. The session is shared by all the task's
+// workers and closed with the task.
+func (d *Downloader) fetchCdn(ctx context.Context, task *DownloadTask, redirect *tg.UploadFileCDNRedirect, offset int64, limit int) ([]byte, error) {
+	task.cdnMu.Lock()
+	s := task.cdn
+	if s == nil {
+		var err error
+		if s, err = d.client.cdnSession(ctx, redirect); err != nil {
+			task.cdnMu.Unlock()
+			return nil, err
+		}
+		task.cdn = s
+		logf("download %s: following cdn redirect to dc %d", task.ID, redirect.DCID)
+	}
+	task.cdnMu.Unlock()
+	return s.fetch(ctx, d.client.API(), offset, limit)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// getHistoryPage fetches one page of channel history, sleeping out any
+// FLOOD_WAIT the server imposes during long walks.
+func getHistoryPage(ctx context.Context, api *tg.Client, req *tg.MessagesGetHistoryRequest) (*tg.MessagesChannelMessages, error) {
+	for {
+		res, err := api.MessagesGetHistory(ctx, req)
+		if wait, ok := tgerr.AsFloodWait(err); ok {
+			logWarnf("history: flood wait %s", wait)
+			select {
+			case <-time.After(wait + time.Second):
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get history: %w", err)
+		}
+		msgs, ok := res.(*tg.MessagesChannelMessages)
+		if !ok {
+			return nil, fmt.Errorf("unexpected history type %T", res)
+		}
+		return msgs, nil
+	}
+}
+
+// mediaRef identifies one media message found during a history walk,
+// with its size when the message carries one, so the queue can route
+// small files into the small-file lane without another round trip.
+type mediaRef struct {
+	ID   int
+	Size int64
+}
+
+// MediaMessages walks the channel and returns all media messages, oldest
+// first. fromID/toID bound the range inclusively; zero means unbounded on
+// that side.
+func (e *ChannelExporter) MediaMessages(ctx context.Context, link *LinkInfo, fromID, toID int) ([]mediaRef, error) {
+	ch, err := e.client.ResolveChannel(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	peer := &tg.InputPeerChannel{ChannelID: ch.ChannelID, AccessHash: ch.AccessHash}
+
+	var refs []mediaRef
+	req := &tg.MessagesGetHistoryRequest{Peer: peer, Limit: 100}
+	if fromID > 0 {
+		req.MinID = fromID - 1
+	}
+	if toID > 0 {
+		req.OffsetID = toID + 1
+	}
+	for {
+		msgs, err := getHistoryPage(ctx, e.api(), req)
+		if err != nil {
+			return nil, err
+		}
+		if len(msgs.Messages) == 0 {
+			break
+		}
+		progressed := false
+		for _, m := range msgs.Messages {
+			msg, ok := m.(*tg.Message)
+			if !ok {
+				continue
+			}
+			req.OffsetID = msg.ID
+			progressed = true
+			if _, hasMedia := msg.GetMedia(); hasMedia {
+				ref := mediaRef{ID: msg.ID}
+				if _, size, _, err := mediaLocation(msg); err == nil {
+					ref.Size = size
+				}
+				refs = append(refs, ref)
+			}
+		}
+		if !progressed {
+			break
+		}
+	}
+	// History pages come newest first; downloads should go oldest first.
+	for i, j := 0, len(refs)-1; i < j; i, j = i+1, j-1 {
+		refs[i], refs[j] = refs[j], refs[i]
+	}
+	return refs, nil
+}
+
+// StartChannelDownload queues a task for every media message in a channel,
+// optionally bounded to the inclusive message-ID range [fromID, toID].
+// Returns a batch ID whose aggregate progress can be polled.
+func (a *App) StartChannelDownload(link string, fromID, toID int, destDir string) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	info, err := ParseLink(link)
+	if err != nil {
+		return respError(err)
+	}
+	refs, err := b.exporter.MediaMessages(context.Background(), info, fromID, toID)
+	if err != nil {
+		return respError(err)
+	}
+	if len(refs) == 0 {
+		return respErr(CodeNotFound, "no media messages in range")
+	}
+
+	a.mu.Lock()
+	a.nextBatchID++
+	batch := &Batch{ID: fmt.Sprintf("batch-%d", a.nextBatchID)}
+	a.mu.Unlock()
+
+	for _, ref := range refs {
+		msgLink := &LinkInfo{
+			Username:  info.Username,
+			ChannelID: info.ChannelID,
+			MessageID: ref.ID,
+			Private:   info.Private,
+		}
+		task := a.newTask(msgLink, destDir, b.name)
+		task.setSize(ref.Size)
+		a.queue.Enqueue(task, 0, func(ctx context.Context) error {
+			return b.downloader.Run(ctx, task)
+		})
+		batch.TaskIDs = append(batch.TaskIDs, task.ID)
+	}
+
+	a.mu.Lock()
+	a.batches[batch.ID] = batch
+	a.mu.Unlock()
+	return respID(batch.ID)
+}
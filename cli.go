@@ -0,0 +1,204 @@
+//go:build headless
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// The headless binary doubles as a command-line tool: given a subcommand
+// it runs that to completion instead of serving the REST API, so shell
+// scripts and cron jobs can drive downloads through the same engine and
+// session the server uses.
+//
+//	teleturbo login                      sign the default account in
+//	teleturbo download <link> -o <dir>   download one link and wait
+//	teleturbo queue ls                   list saved unfinished tasks
+const cliUsage = `usage:
+  teleturbo login                        sign the default account in
+  teleturbo download <link> [-o dir] [-account name]
+  teleturbo queue ls                     list saved unfinished tasks
+
+Without a subcommand the REST API server starts; see -help for its flags.
+`
+
+// runCLI dispatches a subcommand and returns the process exit code.
+func runCLI(args []string) int {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	switch args[0] {
+	case "login":
+		return cliLogin(ctx)
+	case "download":
+		return cliDownload(ctx, args[1:])
+	case "queue":
+		return cliQueue(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s", args[0], cliUsage)
+		return 2
+	}
+}
+
+// cliConnect builds an App and connects the default account, reusing the
+// stored session. The binding Response tells the caller whether the
+// session is already signed in.
+func cliConnect(ctx context.Context) (*App, Response) {
+	app := NewApp("")
+	app.startup(ctx)
+	return app, app.Connect(0, "")
+}
+
+func cliLogin(ctx context.Context) int {
+	app, res := cliConnect(ctx)
+	defer app.shutdown(ctx)
+	if !res.OK() {
+		fmt.Fprintf(os.Stderr, "connect: %s\n", res.Message)
+		return 1
+	}
+	if res.Details == "authorized" {
+		fmt.Println("already logged in")
+		return 0
+	}
+	in := bufio.NewReader(os.Stdin)
+	phone, err := prompt(in, "Phone number (international format): ")
+	if err != nil {
+		return 1
+	}
+	if res := app.SendCode(phone); res.Code != CodeCodeSent {
+		fmt.Fprintf(os.Stderr, "send code: %s\n", res.Message)
+		return 1
+	}
+	code, err := prompt(in, "Login code: ")
+	if err != nil {
+		return 1
+	}
+	if res := app.SignIn(code); !res.OK() {
+		fmt.Fprintf(os.Stderr, "sign in: %s\n", res.Message)
+		return 1
+	}
+	fmt.Println("logged in")
+	return 0
+}
+
+func prompt(in *bufio.Reader, label string) (string, error) {
+	fmt.Print(label)
+	line, err := in.ReadString('\n')
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read input: %v\n", err)
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// cliDownload queues one link and blocks until the task reaches a final
+// state, printing progress on the way; the exit code reflects the
+// outcome, for scripts.
+func cliDownload(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("download", flag.ExitOnError)
+	out := fs.String("o", "", "destination directory (default: the configured one)")
+	account := fs.String("account", "", "account to download through (default: the active one)")
+	// Accept the natural "download <link> -o dir" order: the flag package
+	// stops at the first positional argument, so lift the link out first.
+	var link string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		link, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if link == "" {
+		link = fs.Arg(0)
+	}
+	if link == "" {
+		fmt.Fprintln(os.Stderr, "usage: teleturbo download <link> [-o dir] [-account name]")
+		return 2
+	}
+
+	app, res := cliConnect(ctx)
+	defer app.shutdown(context.Background())
+	if !res.OK() {
+		fmt.Fprintf(os.Stderr, "connect: %s\n", res.Message)
+		return 1
+	}
+	if res.Details != "authorized" {
+		fmt.Fprintln(os.Stderr, "not logged in; run: teleturbo login")
+		return 1
+	}
+	res = app.StartDownloadAs(link, *out, *account)
+	if !res.OK() {
+		fmt.Fprintf(os.Stderr, "download: %s\n", res.Message)
+		return 1
+	}
+	id, _ := res.Details.(string)
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			app.CancelDownload(id)
+			fmt.Fprintln(os.Stderr, "\ninterrupted")
+			return 1
+		case <-ticker.C:
+		}
+		task, ok := app.tasks.Get(id)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "task %s vanished\n", id)
+			return 1
+		}
+		name, size := task.fileMeta()
+		written := atomic.LoadInt64(&task.Written)
+		switch status := task.status(); status {
+		case "done":
+			fmt.Printf("\r%s: done (%s)\n", name, app.FormatBytes(written))
+			return 0
+		case "failed", "expired", "cancelled":
+			fmt.Fprintf(os.Stderr, "\r%s: %s\n", id, status)
+			if msg := task.errText(); msg != "" {
+				fmt.Fprintln(os.Stderr, msg)
+			}
+			return 1
+		default:
+			if size > 0 {
+				fmt.Printf("\r%s %s / %s", status, app.FormatBytes(written), app.FormatBytes(size))
+			} else {
+				fmt.Printf("\r%s %s", status, app.FormatBytes(written))
+			}
+		}
+	}
+}
+
+// cliQueue lists the unfinished tasks saved from previous runs. It reads
+// the persisted task state without connecting, so it works offline and
+// does not disturb a server using the same config dir.
+func cliQueue(args []string) int {
+	if len(args) == 0 || args[0] != "ls" {
+		fmt.Fprintln(os.Stderr, "usage: teleturbo queue ls")
+		return 2
+	}
+	app := NewApp("")
+	tasks := app.ListIncompleteTasks()
+	if len(tasks) == 0 {
+		fmt.Println("queue is empty")
+		return 0
+	}
+	for _, task := range tasks {
+		name, _ := task.fileMeta()
+		if name == "" && task.Link != nil {
+			if task.Link.Username != "" {
+				name = fmt.Sprintf("t.me/%s/%d", task.Link.Username, task.Link.MessageID)
+			} else {
+				name = fmt.Sprintf("t.me/c/%d/%d", task.Link.ChannelID, task.Link.MessageID)
+			}
+		}
+		fmt.Printf("%-10s %-9s %12s  %s\n", task.ID, task.status(), app.FormatBytes(atomic.LoadInt64(&task.Written)), name)
+	}
+	return 0
+}
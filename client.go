@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gotd/contrib/middleware/floodwait"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/auth"
+	"github.com/gotd/td/telegram/dcs"
+	"github.com/gotd/td/tg"
+)
+
+// TGClient wraps a gotd telegram.Client and keeps the connection alive in a
+// background goroutine so the Wails bindings can make API calls at any time.
+type TGClient struct {
+	client     *telegram.Client
+	api        *tg.Client
+	dispatcher tg.UpdateDispatcher
+
+	phone       string
+	codeHash    string
+	stateDir    string
+	sessionPath string
+
+	ready chan struct{}
+	stop  context.CancelFunc
+
+	presenceStop context.CancelFunc
+
+	// budget counts raw calls and flood waits per method; see ratebudget.go.
+	budget *rateTracker
+
+	mu            sync.Mutex
+	poolSize      int
+	poolClose     telegram.CloseInvoker
+	poolAPI       *tg.Client
+	channelCache  map[int64]*tg.InputChannel
+	usernameCache map[string]*tg.InputChannel
+	titleCache    map[int64]string
+	userHashCache map[int64]int64
+}
+
+// ClientOptions configures NewClient beyond the API credentials.
+type ClientOptions struct {
+	// SessionDir overrides where the session and per-account state files
+	// live. Empty means <user config dir>/TeleTurbo.
+	SessionDir string
+	// Proxy routes the connection through a SOCKS5 or HTTP proxy.
+	Proxy *ProxyConfig
+}
+
+// NewClient builds a TGClient for the given API credentials. The MTProto
+// session is persisted under the user's config directory so logins survive
+// restarts; it is only removed by an explicit ResetSession.
+func NewClient(appID int, appHash string, opts ClientOptions) (*TGClient, error) {
+	dir := opts.SessionDir
+	if dir == "" {
+		var err error
+		if dir, err = configDir(); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("session dir: %w", err)
+	}
+	sessionPath := filepath.Join(dir, "session.json")
+
+	c := &TGClient{
+		stateDir:      dir,
+		sessionPath:   sessionPath,
+		dispatcher:    tg.NewUpdateDispatcher(),
+		ready:         make(chan struct{}),
+		channelCache:  make(map[int64]*tg.InputChannel),
+		usernameCache: make(map[string]*tg.InputChannel),
+		titleCache:    make(map[int64]string),
+		userHashCache: make(map[int64]int64),
+		budget:        newRateTracker(),
+	}
+	tgOpts := telegram.Options{
+		// The session is encrypted at rest with a key from the OS
+		// keychain; see sessioncrypt.go for the fallbacks.
+		SessionStorage: newSessionStorage(sessionPath),
+		UpdateHandler:  c.dispatcher,
+		// Every request — auth, resolving, history walks, file chunks —
+		// honors FLOOD_WAIT by waiting it out and retrying instead of
+		// bubbling up a hard error. The budget tracker sits between the
+		// waiter and the wire, so it counts every attempt and every
+		// FLOOD_WAIT the server actually returned, including those the
+		// waiter then sleeps out.
+		Middlewares: []telegram.Middleware{
+			floodwait.NewSimpleWaiter().WithMaxRetries(5),
+			c.budget,
+		},
+	}
+	if opts.Proxy != nil && opts.Proxy.Type != "" {
+		if opts.Proxy.Type == "mtproto" {
+			secret, err := opts.Proxy.mtproxySecret()
+			if err != nil {
+				return nil, fmt.Errorf("mtproxy: %w", err)
+			}
+			resolver, err := dcs.MTProxy(opts.Proxy.addr(), secret, dcs.MTProxyOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("mtproxy: %w", err)
+			}
+			tgOpts.Resolver = resolver
+		} else {
+			dial, err := opts.Proxy.dialFunc()
+			if err != nil {
+				return nil, fmt.Errorf("proxy: %w", err)
+			}
+			tgOpts.Resolver = dcs.Plain(dcs.PlainOptions{Dial: dial})
+		}
+	}
+	c.client = telegram.NewClient(appID, appHash, tgOpts)
+	return c, nil
+}
+
+// Start connects to Telegram and blocks until the connection is usable or
+// fails. The connection then stays up until Stop or ctx cancellation.
+func (c *TGClient) Start(ctx context.Context) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	c.stop = cancel
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- c.client.Run(runCtx, func(ctx context.Context) error {
+			c.api = c.client.API()
+			close(c.ready)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}()
+
+	select {
+	case <-c.ready:
+		return nil
+	case err := <-errC:
+		return err
+	}
+}
+
+// Stop tears down the connection goroutine.
+func (c *TGClient) Stop() {
+	c.stopPresence()
+	c.mu.Lock()
+	if c.poolClose != nil {
+		c.poolClose.Close()
+		c.poolClose, c.poolAPI = nil, nil
+	}
+	c.mu.Unlock()
+	if c.stop != nil {
+		c.stop()
+	}
+}
+
+// SetPoolSize sets how many MTProto connections chunk fetches fan out
+// across; 0 or 1 keeps the single connection. An existing pool is torn
+// down so the next chunk builds one at the new size.
+func (c *TGClient) SetPoolSize(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.poolClose != nil {
+		c.poolClose.Close()
+		c.poolClose, c.poolAPI = nil, nil
+	}
+	c.poolSize = n
+}
+
+// ChunkAPI returns the raw client chunk fetches should use: an invoker
+// fanning across poolSize connections when the pool is enabled, the
+// regular single connection otherwise. The pool is built lazily on first
+// use and shared by every task on this account; one connection tops out
+// well below a fast line, several together saturate it.
+func (c *TGClient) ChunkAPI() *tg.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.poolSize <= 1 {
+		return c.api
+	}
+	if c.poolAPI == nil {
+		invoker, err := c.client.Pool(int64(c.poolSize))
+		if err != nil {
+			logWarnf("pool: %v (using the single connection)", err)
+			return c.api
+		}
+		c.poolClose = invoker
+		c.poolAPI = tg.NewClient(invoker)
+	}
+	return c.poolAPI
+}
+
+// presenceInterval is how often the online status is refreshed while the
+// user opted into appearing online; Telegram expires it after about a
+// minute otherwise.
+const presenceInterval = 45 * time.Second
+
+// SetPresence controls what the account's contacts see while TeleTurbo
+// runs. The default is offline: a downloader should not mark its user
+// "online" all day. Opting in starts a keep-alive that refreshes the
+// status until switched off again.
+func (c *TGClient) SetPresence(ctx context.Context, online bool) error {
+	c.stopPresence()
+	if !online {
+		_, err := c.api.AccountUpdateStatus(ctx, true)
+		return err
+	}
+	if _, err := c.api.AccountUpdateStatus(ctx, false); err != nil {
+		return err
+	}
+	keepCtx, cancel := context.WithCancel(context.Background())
+	c.presenceStop = cancel
+	go func() {
+		ticker := time.NewTicker(presenceInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := c.api.AccountUpdateStatus(keepCtx, false); err != nil {
+					logWarnf("presence: %v", err)
+				}
+			case <-keepCtx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (c *TGClient) stopPresence() {
+	if c.presenceStop != nil {
+		c.presenceStop()
+		c.presenceStop = nil
+	}
+}
+
+// ResetSession disconnects and deletes the stored session file. Use this
+// when the session is corrupted or the user wants to log in from scratch;
+// the client must be recreated afterwards.
+func (c *TGClient) ResetSession() error {
+	c.Stop()
+	if err := os.Remove(c.sessionPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// StateDir is the directory holding the session and other per-account
+// state files.
+func (c *TGClient) StateDir() string {
+	return c.stateDir
+}
+
+// API returns the raw MTProto API. Only valid after Start has returned nil.
+func (c *TGClient) API() *tg.Client {
+	return c.api
+}
+
+// Dispatcher exposes the update dispatcher so watch mode can register
+// handlers before Start is called.
+func (c *TGClient) Dispatcher() *tg.UpdateDispatcher {
+	return &c.dispatcher
+}
+
+// Authorized reports whether the session is already signed in.
+func (c *TGClient) Authorized(ctx context.Context) (bool, error) {
+	status, err := c.client.Auth().Status(ctx)
+	if err != nil {
+		return false, err
+	}
+	return status.Authorized, nil
+}
+
+// SendCode starts phone-number login and stashes the code hash for SignIn.
+func (c *TGClient) SendCode(ctx context.Context, phone string) error {
+	sent, err := c.client.Auth().SendCode(ctx, phone, auth.SendCodeOptions{})
+	if err != nil {
+		return err
+	}
+	code, ok := sent.(*tg.AuthSentCode)
+	if !ok {
+		return fmt.Errorf("unexpected sent code type %T", sent)
+	}
+	c.phone = phone
+	c.codeHash = code.PhoneCodeHash
+	return nil
+}
+
+// SignIn completes login with the OTP code received after SendCode.
+func (c *TGClient) SignIn(ctx context.Context, code string) error {
+	_, err := c.client.Auth().SignIn(ctx, c.phone, code, c.codeHash)
+	return err
+}
+
+// ResolveChannel turns a parsed link into an InputChannel with a valid
+// access hash, caching results so repeated downloads from the same channel
+// don't hit the resolver again.
+func (c *TGClient) ResolveChannel(ctx context.Context, link *LinkInfo) (*tg.InputChannel, error) {
+	c.mu.Lock()
+	if link.Private {
+		if ch, ok := c.channelCache[link.ChannelID]; ok {
+			c.mu.Unlock()
+			return ch, nil
+		}
+	} else if ch, ok := c.usernameCache[link.Username]; ok {
+		c.mu.Unlock()
+		return ch, nil
+	}
+	c.mu.Unlock()
+
+	var full *tg.Channel
+	if link.Private {
+		chats, err := c.api.ChannelsGetChannels(ctx, []tg.InputChannelClass{
+			&tg.InputChannel{ChannelID: link.ChannelID},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("resolve channel %d: %w", link.ChannelID, err)
+		}
+		full = firstChannelChat(chats.GetChats())
+	} else {
+		peer, err := c.api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{Username: link.Username})
+		if err != nil {
+			return nil, fmt.Errorf("resolve @%s: %w", link.Username, err)
+		}
+		full = firstChannelChat(peer.Chats)
+	}
+	if full == nil {
+		return nil, fmt.Errorf("link does not point at a channel")
+	}
+	resolved := &tg.InputChannel{ChannelID: full.ID, AccessHash: full.AccessHash}
+
+	c.mu.Lock()
+	c.channelCache[resolved.ChannelID] = resolved
+	c.titleCache[resolved.ChannelID] = full.Title
+	if link.Username != "" {
+		c.usernameCache[link.Username] = resolved
+	}
+	c.mu.Unlock()
+	return resolved, nil
+}
+
+// ChannelTitle returns the cached title of a resolved channel, or "".
+func (c *TGClient) ChannelTitle(channelID int64) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.titleCache[channelID]
+}
+
+// JoinInvite joins the chat behind an invite hash. If the account is
+// already a member the existing channel is returned without re-joining.
+// The result is cached like ResolveChannel's, so message links into the
+// channel work immediately after the join.
+func (c *TGClient) JoinInvite(ctx context.Context, hash string) (*tg.InputChannel, error) {
+	invite, err := c.api.MessagesCheckChatInvite(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("check invite: %w", err)
+	}
+	var joined *tg.InputChannel
+	if already, ok := invite.(*tg.ChatInviteAlready); ok {
+		joined = firstChannel([]tg.ChatClass{already.Chat})
+	} else {
+		res, err := c.api.MessagesImportChatInvite(ctx, hash)
+		if err != nil {
+			return nil, fmt.Errorf("join invite: %w", err)
+		}
+		if okRes, ok := res.(*tg.MessagesChatInviteJoinResultOk); ok {
+			if ups, ok := okRes.Updates.(*tg.Updates); ok {
+				joined = firstChannel(ups.Chats)
+			}
+		}
+	}
+	if joined == nil {
+		return nil, fmt.Errorf("invite does not point at a channel")
+	}
+	c.mu.Lock()
+	c.channelCache[joined.ChannelID] = joined
+	c.mu.Unlock()
+	return joined, nil
+}
+
+// GetMessages fetches several messages from a channel in one round trip.
+// Missing IDs are simply absent from the result.
+func (c *TGClient) GetMessages(ctx context.Context, ch *tg.InputChannel, msgIDs []int) ([]*tg.Message, error) {
+	ids := make([]tg.InputMessageClass, 0, len(msgIDs))
+	for _, id := range msgIDs {
+		ids = append(ids, &tg.InputMessageID{ID: id})
+	}
+	res, err := c.api.ChannelsGetMessages(ctx, &tg.ChannelsGetMessagesRequest{
+		Channel: ch,
+		ID:      ids,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get messages: %w", err)
+	}
+	msgs, ok := res.(*tg.MessagesChannelMessages)
+	if !ok {
+		return nil, fmt.Errorf("unexpected messages type %T", res)
+	}
+	out := make([]*tg.Message, 0, len(msgs.Messages))
+	for _, m := range msgs.Messages {
+		if msg, ok := m.(*tg.Message); ok {
+			out = append(out, msg)
+		}
+	}
+	return out, nil
+}
+
+// messageGoneError marks a message that no longer exists: deleted, or
+// the ID was never valid. Callers distinguish it from transient failures,
+// e.g. to expire a stale queued task instead of retrying it.
+type messageGoneError struct{ id int }
+
+func (e *messageGoneError) Error() string {
+	return fmt.Sprintf("message %d not found", e.id)
+}
+
+// GetMessage fetches a single message from a channel by ID.
+func (c *TGClient) GetMessage(ctx context.Context, ch *tg.InputChannel, msgID int) (*tg.Message, error) {
+	msgs, err := c.GetMessages(ctx, ch, []int{msgID})
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range msgs {
+		if msg.ID == msgID {
+			return msg, nil
+		}
+	}
+	return nil, &messageGoneError{id: msgID}
+}
+
+func firstChannelChat(chats []tg.ChatClass) *tg.Channel {
+	for _, chat := range chats {
+		if ch, ok := chat.(*tg.Channel); ok {
+			return ch
+		}
+	}
+	return nil
+}
+
+func firstChannel(chats []tg.ChatClass) *tg.InputChannel {
+	ch := firstChannelChat(chats)
+	if ch == nil {
+		return nil
+	}
+	return &tg.InputChannel{ChannelID: ch.ID, AccessHash: ch.AccessHash}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// clockSkewLimit is how far the system clock may drift from Telegram's
+// before it is worth warning about. The MTProto layer resynchronizes
+// message IDs and salts from the server's bad_msg notifications on its
+// own, but a clock this far off still produces confusing auth failures,
+// wrong schedule dispatches and misleading timestamps.
+const clockSkewLimit = 2 * time.Minute
+
+// ClockSkew measures how far the local clock is ahead of Telegram's
+// (negative: behind). It piggybacks on updates.getState — the lightest
+// authorized call that carries a server timestamp — and splits the round
+// trip to place the server's reading mid-flight. Second resolution;
+// sub-second drift is noise.
+func (c *TGClient) ClockSkew(ctx context.Context) (time.Duration, error) {
+	before := time.Now()
+	state, err := c.api.UpdatesGetState(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("measure clock skew: %w", err)
+	}
+	rtt := time.Since(before)
+	local := before.Add(rtt / 2)
+	server := time.Unix(int64(state.Date), 0)
+	return local.Sub(server).Truncate(time.Second), nil
+}
+
+// CheckClock measures the active account's clock skew and turns an
+// out-of-bounds result into a clear error instead of the cryptic auth
+// failures a wrong clock otherwise causes. The measured skew in seconds
+// rides in Details either way.
+func (a *App) CheckClock() Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	skew, err := b.client.ClockSkew(a.ctx)
+	if err != nil {
+		return respError(err)
+	}
+	if skew > clockSkewLimit || skew < -clockSkewLimit {
+		direction := "ahead"
+		if skew < 0 {
+			direction = "behind"
+		}
+		minutes := int(skew.Abs().Round(time.Minute) / time.Minute)
+		return Response{
+			Code:    CodeConflict,
+			Message: fmt.Sprintf("system clock is off by %d minutes (%s of Telegram); fix the clock or enable NTP", minutes, direction),
+			Details: int(skew.Seconds()),
+		}
+	}
+	return Response{Code: CodeOK, Details: int(skew.Seconds())}
+}
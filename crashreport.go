@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// appVersion is stamped into crash and support bundles so reports can be
+// matched to a release.
+const appVersion = "1.0.0"
+
+// logRingSize is how many recent log lines are kept for bundles.
+const logRingSize = 500
+
+// logRing keeps the most recent log lines in memory. The structured
+// logger (logging.go) writes to stdout among its sinks, so the ring is
+// fed by teeing stdout and catches CLI output and stray prints too.
+type logRing struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+func (r *logRing) add(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.lines) < logRingSize {
+		r.lines = append(r.lines, line)
+		return
+	}
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % logRingSize
+	r.full = true
+}
+
+// tail returns the buffered lines, oldest first.
+func (r *logRing) tail() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]string(nil), r.lines...)
+	}
+	out := make([]string, 0, len(r.lines))
+	out = append(out, r.lines[r.next:]...)
+	out = append(out, r.lines[:r.next]...)
+	return out
+}
+
+// recentLogs collects stdout so crash bundles can include what the app was
+// doing right before a failure.
+var recentLogs = &logRing{}
+
+// startLogCapture replaces stdout with a pipe that forwards every line to
+// the real stdout while keeping a copy in recentLogs.
+func startLogCapture() {
+	orig := os.Stdout
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return
+	}
+	os.Stdout = pw
+	go func() {
+		buf := make([]byte, 4096)
+		var line strings.Builder
+		for {
+			n, err := pr.Read(buf)
+			if n > 0 {
+				orig.Write(buf[:n])
+				for _, b := range buf[:n] {
+					if b == '\n' {
+						recentLogs.add(line.String())
+						line.Reset()
+					} else {
+						line.WriteByte(b)
+					}
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+}
+
+var (
+	phonePattern = regexp.MustCompile(`\+?\d{7,15}`)
+	tokenPattern = regexp.MustCompile(`\b\d+:[\w-]{30,}\b`)
+)
+
+// redact strips personal data from bundled text: phone numbers, bot-style
+// tokens and the user's home directory path.
+func redact(s string) string {
+	s = tokenPattern.ReplaceAllString(s, "[token]")
+	s = phonePattern.ReplaceAllString(s, "[phone]")
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		s = strings.ReplaceAll(s, home, "~")
+	}
+	return s
+}
+
+// bundledFiles are the state files worth copying into a bundle. Session
+// files are deliberately excluded: they hold auth keys.
+var bundledFiles = []string{"tasks.json", "schedule.json", "jobs.json", "history.json"}
+
+// writeSupportBundle collects a crash or diagnostics bundle into a fresh
+// directory under the config dir and returns its path. Nothing is sent
+// anywhere; sharing the bundle is the user's call.
+func writeSupportBundle(reason string, stack []byte) (string, error) {
+	base, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, "reports", time.Now().Format("20060102-150405"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "time: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&report, "app: TeleTurbo %s\n", appVersion)
+	fmt.Fprintf(&report, "go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&report, "reason: %s\n", redact(reason))
+	if len(stack) > 0 {
+		fmt.Fprintf(&report, "\n%s", redact(string(stack)))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "report.txt"), []byte(report.String()), 0o644); err != nil {
+		return "", err
+	}
+
+	logs := strings.Join(recentLogs.tail(), "\n")
+	if err := os.WriteFile(filepath.Join(dir, "logs.txt"), []byte(redact(logs)), 0o644); err != nil {
+		return "", err
+	}
+
+	for _, name := range bundledFiles {
+		data, err := os.ReadFile(filepath.Join(base, name))
+		if err != nil {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(redact(string(data))), 0o644); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// reportPanic is deferred in main; on panic it writes a crash bundle and
+// then lets the process die with the original panic.
+func reportPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if dir, err := writeSupportBundle(fmt.Sprintf("panic: %v", r), debug.Stack()); err == nil {
+		logErrorf("crash bundle written to %s", dir)
+	}
+	panic(r)
+}
+
+// CreateSupportBundle writes a diagnostics bundle (versions, redacted
+// recent logs, state files) without a crash and returns its path.
+func (a *App) CreateSupportBundle() Response {
+	dir, err := writeSupportBundle("support bundle requested", nil)
+	if err != nil {
+		return respError(err)
+	}
+	return respID(dir)
+}
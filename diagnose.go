@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Diagnosis is one ranked bottleneck hypothesis for a slow download.
+type Diagnosis struct {
+	Likelihood string `json:"likelihood"` // high, medium, low
+	Cause      string `json:"cause"`
+	Suggestion string `json:"suggestion"`
+}
+
+func likelihoodRank(l string) int {
+	switch l {
+	case "high":
+		return 0
+	case "medium":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// diskProbeSize is how much the diagnosis writes to measure destination
+// write speed; small enough to be quick, large enough to defeat caches
+// that hide a slow USB drive or network share entirely.
+const diskProbeSize = 8 << 20
+
+// probeWriteSpeed measures the destination's sequential write speed in
+// bytes per second using a throwaway file.
+func probeWriteSpeed(dir string) (float64, error) {
+	path := filepath.Join(dir, ".teleturbo-diagprobe")
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(path)
+	defer f.Close()
+	buf := make([]byte, 1<<20)
+	start := time.Now()
+	for written := 0; written < diskProbeSize; written += len(buf) {
+		if _, err := f.Write(buf); err != nil {
+			return 0, err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	secs := time.Since(start).Seconds()
+	if secs == 0 {
+		return 0, nil
+	}
+	return diskProbeSize / secs, nil
+}
+
+// DiagnoseSlowDownload inspects a task's telemetry — achieved speed
+// against configured caps, flood waits, chunk retries, peak-versus-
+// average shape and a destination write-speed probe — and returns likely
+// bottlenecks ranked by likelihood, each with a suggested change.
+func (a *App) DiagnoseSlowDownload(id string) []Diagnosis {
+	task, ok := a.tasks.Get(id)
+	if !ok {
+		return nil
+	}
+	var out []Diagnosis
+
+	written := atomic.LoadInt64(&task.Written)
+	var avgBps float64
+	if started := task.started(); !started.IsZero() {
+		if secs := time.Since(started).Seconds(); secs > 0 {
+			avgBps = float64(written) / secs
+		}
+	}
+	if report := task.report(); report != nil {
+		avgBps = report.AvgBps
+	}
+	nearCap := func(limit int64) bool {
+		return limit > 0 && avgBps > 0.85*float64(limit)
+	}
+
+	if limit := task.speedLimit(); nearCap(limit) {
+		out = append(out, Diagnosis{
+			Likelihood: "high",
+			Cause:      fmt.Sprintf("running at the task's own %s/s cap", a.FormatBytes(limit)),
+			Suggestion: "raise or clear the per-task limit via SetTaskSpeedLimit",
+		})
+	}
+	if nearCap(a.globalLimit.Rate()) {
+		out = append(out, Diagnosis{
+			Likelihood: "high",
+			Cause:      fmt.Sprintf("running at the global %s/s cap", a.FormatBytes(a.globalLimit.Rate())),
+			Suggestion: "raise or clear the global limit via SetGlobalSpeedLimit",
+		})
+	}
+	if !a.isTaskFocused(task.ID) && nearCap(a.bgLimit.Rate()) {
+		out = append(out, Diagnosis{
+			Likelihood: "high",
+			Cause:      "task is unfocused and capped by the background limit",
+			Suggestion: "focus the task's detail view or raise the background limit",
+		})
+	}
+	if waits := atomic.LoadInt64(&task.floodWaits); waits > 0 {
+		out = append(out, Diagnosis{
+			Likelihood: "high",
+			Cause:      fmt.Sprintf("Telegram rate limiting: %d flood waits so far", waits),
+			Suggestion: "lower the number of concurrent downloads; the waits are server-imposed and more parallelism makes them worse",
+		})
+	}
+	if retries := atomic.LoadInt64(&task.retries); retries >= 3 {
+		out = append(out, Diagnosis{
+			Likelihood: "medium",
+			Cause:      fmt.Sprintf("flaky connection: %d chunk retries so far", retries),
+			Suggestion: "check the network path or configured proxy; retries cost backoff delays",
+		})
+	}
+	if peak := atomic.LoadInt64(&task.peakBps); peak > 0 && avgBps > 0 && float64(peak) > 3*avgBps {
+		out = append(out, Diagnosis{
+			Likelihood: "medium",
+			Cause:      fmt.Sprintf("bursty transfer: peak %s/s but average %s/s", a.FormatBytes(peak), a.FormatBytes(int64(avgBps))),
+			Suggestion: "the link is capable but stalls between bursts; look at the flood-wait and retry findings above",
+		})
+	}
+	if diskBps, err := probeWriteSpeed(task.DestDir); err == nil && avgBps > 0 && diskBps < 2*avgBps {
+		out = append(out, Diagnosis{
+			Likelihood: "medium",
+			Cause:      fmt.Sprintf("destination writes at %s/s, close to the transfer speed", a.FormatBytes(int64(diskBps))),
+			Suggestion: "the destination volume is the bottleneck; download to a faster disk and move the file afterwards",
+		})
+	}
+	if len(out) == 0 {
+		out = append(out, Diagnosis{
+			Likelihood: "low",
+			Cause:      "no configured cap, rate limiting or disk bottleneck detected",
+			Suggestion: "the speed likely reflects the Telegram DC or your line; compare against speeds at other hours via GetAnalytics",
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return likelihoodRank(out[i].Likelihood) < likelihoodRank(out[j].Likelihood)
+	})
+	return out
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gotd/td/tg"
+)
+
+// DialogInfo is one entry of the in-app chat browser: enough to render a
+// channel picker and build links without pasting t.me URLs.
+type DialogInfo struct {
+	ID       int64  `json:"id"`
+	Title    string `json:"title"`
+	Username string `json:"username"`
+	Type     string `json:"type"` // channel, group, user, bot
+	Unread   int    `json:"unread"`
+	PhotoID  int64  `json:"photoId"`
+}
+
+// dialogsPageSize is how many dialogs each messages.getDialogs call asks
+// for while walking to the requested offset.
+const dialogsPageSize = 100
+
+// Dialogs returns the account's dialogs ordered as Telegram orders them,
+// skipping offset entries and returning at most limit. Pagination walks
+// messages.getDialogs pages using the last dialog's top message as the
+// next offset, as the API requires.
+func (c *TGClient) Dialogs(ctx context.Context, offset, limit int) ([]DialogInfo, error) {
+	if limit <= 0 {
+		limit = dialogsPageSize
+	}
+	var collected []DialogInfo
+	offsetPeer := tg.InputPeerClass(&tg.InputPeerEmpty{})
+	offsetDate, offsetID := 0, 0
+	for len(collected) < offset+limit {
+		res, err := c.API().MessagesGetDialogs(ctx, &tg.MessagesGetDialogsRequest{
+			OffsetDate: offsetDate,
+			OffsetID:   offsetID,
+			OffsetPeer: offsetPeer,
+			Limit:      dialogsPageSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get dialogs: %w", err)
+		}
+		dialogs, messages, chats, users, ok := splitDialogs(res)
+		if !ok || len(dialogs) == 0 {
+			break
+		}
+		for _, d := range dialogs {
+			collected = append(collected, dialogInfo(d, chats, users))
+		}
+		last, ok := dialogs[len(dialogs)-1].(*tg.Dialog)
+		if !ok {
+			break
+		}
+		offsetPeer = inputPeerFor(last.Peer, chats, users)
+		offsetID = last.TopMessage
+		offsetDate = messageDate(messages, last.TopMessage)
+		if len(dialogs) < dialogsPageSize {
+			break
+		}
+	}
+	if offset >= len(collected) {
+		return nil, nil
+	}
+	if end := offset + limit; end < len(collected) {
+		return collected[offset:end], nil
+	}
+	return collected[offset:], nil
+}
+
+// splitDialogs unpacks the two non-empty messages.Dialogs variants.
+func splitDialogs(res tg.MessagesDialogsClass) ([]tg.DialogClass, []tg.MessageClass, []tg.ChatClass, []tg.UserClass, bool) {
+	switch d := res.(type) {
+	case *tg.MessagesDialogs:
+		return d.Dialogs, d.Messages, d.Chats, d.Users, true
+	case *tg.MessagesDialogsSlice:
+		return d.Dialogs, d.Messages, d.Chats, d.Users, true
+	default:
+		return nil, nil, nil, nil, false
+	}
+}
+
+// dialogInfo resolves one dialog's peer against the response's chat and
+// user lists.
+func dialogInfo(d tg.DialogClass, chats []tg.ChatClass, users []tg.UserClass) DialogInfo {
+	dlg, ok := d.(*tg.Dialog)
+	if !ok {
+		return DialogInfo{Type: "folder"}
+	}
+	info := DialogInfo{Unread: dlg.UnreadCount}
+	switch peer := dlg.Peer.(type) {
+	case *tg.PeerChannel:
+		info.ID = peer.ChannelID
+		for _, c := range chats {
+			ch, ok := c.(*tg.Channel)
+			if !ok || ch.ID != peer.ChannelID {
+				continue
+			}
+			info.Title = ch.Title
+			info.Username = ch.Username
+			if ch.Megagroup {
+				info.Type = "group"
+			} else {
+				info.Type = "channel"
+			}
+			if photo, ok := ch.Photo.(*tg.ChatPhoto); ok {
+				info.PhotoID = photo.PhotoID
+			}
+		}
+	case *tg.PeerChat:
+		info.ID = peer.ChatID
+		info.Type = "group"
+		for _, c := range chats {
+			ch, ok := c.(*tg.Chat)
+			if !ok || ch.ID != peer.ChatID {
+				continue
+			}
+			info.Title = ch.Title
+			if photo, ok := ch.Photo.(*tg.ChatPhoto); ok {
+				info.PhotoID = photo.PhotoID
+			}
+		}
+	case *tg.PeerUser:
+		info.ID = peer.UserID
+		info.Type = "user"
+		for _, u := range users {
+			user, ok := u.(*tg.User)
+			if !ok || user.ID != peer.UserID {
+				continue
+			}
+			info.Title = user.FirstName
+			if user.LastName != "" {
+				info.Title += " " + user.LastName
+			}
+			info.Username = user.Username
+			if user.Bot {
+				info.Type = "bot"
+			}
+			if photo, ok := user.Photo.(*tg.UserProfilePhoto); ok {
+				info.PhotoID = photo.PhotoID
+			}
+		}
+	}
+	return info
+}
+
+// inputPeerFor builds the offset peer for the next dialogs page.
+func inputPeerFor(peer tg.PeerClass, chats []tg.ChatClass, users []tg.UserClass) tg.InputPeerClass {
+	switch p := peer.(type) {
+	case *tg.PeerChannel:
+		for _, c := range chats {
+			if ch, ok := c.(*tg.Channel); ok && ch.ID == p.ChannelID {
+				return &tg.InputPeerChannel{ChannelID: ch.ID, AccessHash: ch.AccessHash}
+			}
+		}
+	case *tg.PeerChat:
+		return &tg.InputPeerChat{ChatID: p.ChatID}
+	case *tg.PeerUser:
+		for _, u := range users {
+			if user, ok := u.(*tg.User); ok && user.ID == p.UserID {
+				return &tg.InputPeerUser{UserID: user.ID, AccessHash: user.AccessHash}
+			}
+		}
+	}
+	return &tg.InputPeerEmpty{}
+}
+
+// messageDate finds the date of the message with the given ID, for the
+// next page's offsetDate.
+func messageDate(messages []tg.MessageClass, id int) int {
+	for _, m := range messages {
+		if msg, ok := m.(*tg.Message); ok && msg.ID == id {
+			return msg.Date
+		}
+	}
+	return 0
+}
+
+// GetDialogs lists the active account's chats for the in-app channel
+// picker. offset and limit page through the full dialog list.
+func (a *App) GetDialogs(offset, limit int) []DialogInfo {
+	b := a.active()
+	if b == nil {
+		return nil
+	}
+	dialogs, err := b.client.Dialogs(a.ctx, offset, limit)
+	if err != nil {
+		a.emit("dialogs:error", err.Error())
+		return nil
+	}
+	return dialogs
+}
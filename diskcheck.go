@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// diskSpaceMargin is kept free on top of the bytes a download needs, so a
+// full transfer never runs the volume completely dry.
+const diskSpaceMargin = 256 * 1024 * 1024
+
+// diskCheckInterval is how often a running download re-checks free space.
+const diskCheckInterval = 5 * time.Second
+
+// diskFullError is the typed failure for insufficient space; its message
+// starts with DISK_FULL so frontends can match it.
+type diskFullError struct {
+	need int64
+	free int64
+}
+
+func (e *diskFullError) Error() string {
+	return fmt.Sprintf("DISK_FULL: need %d bytes plus margin, only %d free", e.need, e.free)
+}
+
+// checkDiskSpace fails when the destination volume cannot hold need more
+// bytes plus the safety margin.
+func checkDiskSpace(dir string, need int64) error {
+	free, err := freeSpace(dir)
+	if err != nil {
+		// Not being able to measure is no reason to refuse the download.
+		return nil
+	}
+	if int64(free) < need+diskSpaceMargin {
+		return &diskFullError{need: need, free: int64(free)}
+	}
+	return nil
+}
+
+// monitorDiskSpace watches the destination volume while a task runs and
+// pauses the task with a clear status when the disk fills up. It returns
+// when ctx ends.
+func monitorDiskSpace(ctx context.Context, task *DownloadTask) {
+	ticker := time.NewTicker(diskCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			free, err := freeSpace(task.DestDir)
+			if err != nil {
+				continue
+			}
+			if int64(free) < diskSpaceMargin {
+				_, size := task.fileMeta()
+				task.setError((&diskFullError{need: size, free: int64(free)}).Error())
+				logWarnf("download %s: disk full, pausing", task.ID)
+				task.Pause()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// freeSpace returns the free bytes available to the user on the volume
+// holding path.
+func freeSpace(path string) (int64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
@@ -0,0 +1,1442 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+const defaultPartSize = 512 * 1024
+
+// partSuffix marks an in-progress download. Data is written to
+// "<name>.teleturbo.part" and renamed to the final name only on success,
+// so an interrupted run can never leave a truncated file that looks
+// complete; the .part file is also what Resume anchors on.
+const partSuffix = ".teleturbo.part"
+
+// smallPartSize is used for files under smallFileThreshold: tiny files
+// gain nothing from big parts, and smaller requests keep the many
+// concurrent small-lane downloads from bursting.
+const smallPartSize = 128 * 1024
+
+// maxThreads caps configured parallelism. Telegram tolerates well beyond
+// the old fixed worker counts on fast links, but past this the extra
+// connections only buy flood waits.
+const maxThreads = 64
+
+// partSizeFor picks the transfer part size for a task based on its known
+// size; unknown sizes get the default. An explicit per-task part size
+// wins over the heuristics.
+func partSizeFor(task *DownloadTask) int {
+	if task.PartSize > 0 {
+		return task.PartSize
+	}
+	if task.Size > 0 && task.Size < smallFileThreshold {
+		return smallPartSize
+	}
+	return defaultPartSize
+}
+
+// validPartSize reports whether n is a part size upload.getFile accepts:
+// a multiple of 4 KiB that divides 1 MiB evenly, so offsets stay aligned
+// for the whole file.
+func validPartSize(n int) bool {
+	return n >= 4096 && n <= 1<<20 && n%4096 == 0 && (1<<20)%n == 0
+}
+
+// DownloadTask tracks one file download end to end. Progress fields are
+// written atomically by the download goroutine and read by the UI bindings.
+type DownloadTask struct {
+	ID       string    `json:"id"`
+	Link     *LinkInfo `json:"link"`
+	DestDir  string    `json:"destDir"`
+	Account  string    `json:"account"`
+	FileName string    `json:"fileName"`
+	Size     int64     `json:"size"`
+	Written  int64     `json:"written"`
+	Status   string    `json:"status"` // scheduled, queued, running, paused, done, failed, cancelled, expired
+	Error    string    `json:"error"`
+	// TTLSeconds is non-zero for self-destructing media; such files are
+	// saved with an "expiring_" prefix so they're clearly labeled.
+	TTLSeconds int `json:"ttlSeconds"`
+	// Variant selects an alt media version reported by Probe; 0 is the
+	// primary document.
+	Variant int `json:"variant"`
+	// Quality is the preference ("highest", "lowest", "720p") that picked
+	// the variant automatically; recorded for the task metadata.
+	Quality string `json:"quality"`
+	// AlbumCount is the number of items when the task covers a whole
+	// grouped-media album; 0 for single files.
+	AlbumCount int `json:"albumCount"`
+	// LimitBytesPerSec is this task's own speed cap; 0 means no per-task
+	// limit. Global and background limits still apply on top.
+	LimitBytesPerSec int64 `json:"limitBytesPerSec"`
+	// DependsOn lists task IDs that must finish successfully before this
+	// task is dispatched.
+	DependsOn []string `json:"dependsOn"`
+	// Deadline, when set, is the moment the task must be finished by;
+	// past it the task is paused and a deadline_missed event is emitted.
+	Deadline time.Time `json:"deadline"`
+	// StartAt holds a scheduled task's dispatch time; the task enters the
+	// queue once it passes.
+	StartAt time.Time `json:"startAt"`
+	// Timezone is the IANA zone the schedule was given in, e.g.
+	// "Europe/Berlin". The wall-clock time resolves against the zone's
+	// offset on the target date, so schedules stay correct across DST
+	// transitions; empty means StartAt was given as a fixed instant.
+	Timezone string `json:"timezone,omitempty"`
+	// NextStart is the planned dispatch time rendered in the schedule's
+	// own zone, for display; "" when the task was never scheduled.
+	NextStart string `json:"nextStart,omitempty"`
+	// NameTemplate, when set, renders the output path relative to DestDir
+	// from template variables; see templateVars.
+	NameTemplate string `json:"nameTemplate"`
+	// Verify requests post-download hash verification for this task even
+	// when the global toggle is off.
+	Verify bool `json:"verify"`
+	// Integrity is "" until a verification ran, then "verified" or
+	// "corrupted".
+	Integrity string `json:"integrity"`
+	// Report is the acceleration report, filled in when the task finishes.
+	Report *AccelReport `json:"report,omitempty"`
+	// Strategy picks the range-fetch order: "sequential" (the default),
+	// "random" or "rarest"; see chunkOrder. Non-sequential orders grow the
+	// contiguous prefix slowly, so an interrupt leaves less for Resume.
+	Strategy string `json:"strategy,omitempty"`
+	// RangeBytes limits the download to the first N bytes of the file,
+	// e.g. to check a video's quality before committing to all of it. The
+	// result is saved under a .partial suffix; PromotePartial upgrades it
+	// to a full download that reuses the bytes.
+	RangeBytes int64 `json:"rangeBytes,omitempty"`
+	// Suspicious marks a zero-byte file downloaded under the "flag"
+	// policy, so batch reports can call it out.
+	Suspicious bool `json:"suspicious,omitempty"`
+	// Threads overrides the downloader's worker count for this task;
+	// 0 uses the global setting.
+	Threads int `json:"threads,omitempty"`
+	// PartSize overrides the chunk size for this task; 0 picks it from
+	// the file size. Must satisfy validPartSize.
+	PartSize int `json:"partSize,omitempty"`
+
+	cancel      context.CancelFunc
+	postCancel  context.CancelFunc
+	pauseWanted int32
+	limit       *rateLimiter
+	startedAt   time.Time
+	channelID   int64
+	retries     int64
+	peakBps     int64
+	floodWaits  int64
+
+	// mu guards the mutable fields download goroutines write while
+	// bindings read and marshal the task concurrently: Status, Error,
+	// FileName, Size, schedule and verification state. Written and the
+	// counters stay atomic; fields set once before the task is published
+	// (ID, Link, DestDir, ...) need no lock. Access goes through the
+	// accessors below MarshalJSON.
+	mu sync.Mutex
+
+	// cdn is the CDN DC session opened when the server redirects this
+	// file's chunks; shared by the task's workers, closed with the task.
+	cdnMu sync.Mutex
+	cdn   *cdnSession
+}
+
+// closeCdn tears down the task's CDN session, if one was opened.
+func (t *DownloadTask) closeCdn() {
+	t.cdnMu.Lock()
+	if t.cdn != nil {
+		t.cdn.Close()
+		t.cdn = nil
+	}
+	t.cdnMu.Unlock()
+}
+
+// SetSpeedLimit changes the task's own cap; it takes effect on the next
+// write even while the task is running.
+func (t *DownloadTask) SetSpeedLimit(bytesPerSec int64) {
+	t.mu.Lock()
+	t.LimitBytesPerSec = bytesPerSec
+	t.mu.Unlock()
+	if t.limit != nil {
+		t.limit.SetRate(bytesPerSec)
+	}
+}
+
+// Downloader resolves a task's media and streams it to disk, emitting
+// progress events for the frontend along the way.
+type Downloader struct {
+	client  *TGClient
+	threads int
+	emit    func(event string, data interface{})
+	breaker *channelBreaker
+
+	// background, when set, caps the combined speed of tasks that are
+	// not focused; focused reports whether a task has the user's focus.
+	background *rateLimiter
+	focused    func(taskID string) bool
+	// global, when set, caps the combined speed of all tasks regardless
+	// of focus.
+	global *rateLimiter
+	// history, when set, receives a record per finished or failed task
+	// for the analytics view.
+	history *transferLog
+	// verifyAll turns on post-download hash verification for every task.
+	verifyAll bool
+	// durability, when set, resolves the destination's durability policy
+	// (none, fsync, readback) applied on finalize.
+	durability func(destDir string) string
+	// locs remembers resolved media locations so re-downloads can start
+	// without a peer resolution or message fetch.
+	locs *locationCache
+	// zeroByte is the policy for files that resolve to zero bytes; see
+	// SetZeroBytePolicy.
+	zeroByte string
+}
+
+// Zero-byte file policies. Empty documents are occasionally stubs or
+// spam, so the default fetch can be replaced by skipping them outright or
+// by flagging them for batch reports.
+const (
+	zeroByteDownload = ""     // fetch like any other file
+	zeroByteSkip     = "skip" // finish immediately without touching disk
+	zeroByteFlag     = "flag" // download, but mark the task suspicious
+)
+
+// SetZeroBytePolicy picks how files that resolve to zero bytes are
+// handled.
+func (d *Downloader) SetZeroBytePolicy(policy string) {
+	d.zeroByte = policy
+}
+
+// skipOrFlagEmpty applies the zero-byte policy to a task whose media
+// resolved to zero bytes. Reports whether the task was finished here;
+// under the flag policy the task is marked suspicious and proceeds.
+func (d *Downloader) skipOrFlagEmpty(task *DownloadTask) (bool, error) {
+	switch d.zeroByte {
+	case zeroByteSkip:
+		logf("download %s: skipping zero-byte file %s", task.ID, task.FileName)
+		return true, d.finish(task)
+	case zeroByteFlag:
+		task.flagSuspicious()
+		logWarnf("download %s: zero-byte file %s flagged as suspicious", task.ID, task.FileName)
+	}
+	return false, nil
+}
+
+// SetDurability wires the per-destination durability policy lookup.
+func (d *Downloader) SetDurability(lookup func(destDir string) string) {
+	d.durability = lookup
+}
+
+// policyFor is the task's durability policy, defaulting to none.
+func (d *Downloader) policyFor(task *DownloadTask) string {
+	if d.durability == nil {
+		return durabilityNone
+	}
+	return d.durability(task.DestDir)
+}
+
+// SetVerifyAll toggles hash verification for all downloads.
+func (d *Downloader) SetVerifyAll(on bool) {
+	d.verifyAll = on
+}
+
+// SetHistory wires the shared transfer history log.
+func (d *Downloader) SetHistory(log *transferLog) {
+	d.history = log
+}
+
+// SetGlobalLimit wires the shared limiter applied to every task.
+func (d *Downloader) SetGlobalLimit(limiter *rateLimiter) {
+	d.global = limiter
+}
+
+// SetBackgroundLimit wires the shared background limiter and the focus
+// check used for differential foreground/background speeds.
+func (d *Downloader) SetBackgroundLimit(limiter *rateLimiter, focused func(taskID string) bool) {
+	d.background = limiter
+	d.focused = focused
+}
+
+// throttleFor builds the per-write throttle for a task, or nil when no
+// limit can apply. Limits stack: the task's own cap, then the global cap,
+// then the background cap for unfocused tasks.
+func (d *Downloader) throttleFor(task *DownloadTask) func(ctx context.Context, n int) {
+	if task.limit == nil && d.global == nil && d.background == nil {
+		return nil
+	}
+	return func(ctx context.Context, n int) {
+		if task.limit != nil {
+			task.limit.Wait(ctx, n)
+		}
+		if d.global != nil {
+			d.global.Wait(ctx, n)
+		}
+		if d.background != nil && !(d.focused != nil && d.focused(task.ID)) {
+			d.background.Wait(ctx, n)
+		}
+	}
+}
+
+// NewDownloader wires a Downloader to a connected client. emit may be nil
+// when no UI is listening (e.g. channel sync).
+func NewDownloader(client *TGClient, threads int, emit func(event string, data interface{})) *Downloader {
+	if threads <= 0 {
+		threads = 4
+	}
+	if emit == nil {
+		emit = func(string, interface{}) {}
+	}
+	return &Downloader{client: client, threads: threads, emit: emit, breaker: newChannelBreaker(emit), locs: newLocationCache()}
+}
+
+// prepare resolves the task's message and extracts its media location.
+func (d *Downloader) prepare(ctx context.Context, task *DownloadTask) (tg.InputFileLocationClass, int64, string, error) {
+	if task.Link.Peer != "" {
+		msg, err := d.client.GetMessageDirect(ctx, task.Link.MessageID)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		return d.locationForTask(task, msg)
+	}
+	ch, err := d.client.ResolveChannel(ctx, task.Link)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	task.channelID = ch.ChannelID
+	msg, err := d.client.GetMessage(ctx, ch, task.Link.MessageID)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	return d.locationForTask(task, msg)
+}
+
+// locationForTask applies variant selection and TTL labeling to a fetched
+// message and returns what to download.
+func (d *Downloader) locationForTask(task *DownloadTask, msg *tg.Message) (tg.InputFileLocationClass, int64, string, error) {
+	loc, size, name, err := mediaLocation(msg)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	// A quality preference picks the variant automatically unless the
+	// user already chose one explicitly.
+	if task.Variant == 0 && task.Quality != "" {
+		task.Variant = pickVariantIndex(variantDocuments(msg), task.Quality)
+	}
+	// A probed alt version replaces the primary document when requested;
+	// out-of-range indexes degrade gracefully to the primary.
+	if task.Variant > 0 {
+		if docs := variantDocuments(msg); task.Variant < len(docs) {
+			doc := docs[task.Variant]
+			loc = &tg.InputDocumentFileLocation{
+				ID:            doc.ID,
+				AccessHash:    doc.AccessHash,
+				FileReference: doc.FileReference,
+			}
+			size = doc.Size
+			if n := documentFileName(doc); n != "" {
+				name = n
+			} else {
+				name = fmt.Sprintf("file_%d_v%d", msg.ID, task.Variant)
+			}
+		}
+	}
+	// Best effort for self-destructing media: if the server still hands
+	// out the media object we can fetch it like any other file, but the
+	// result is labeled so users know it was an expiring post.
+	if ttl := mediaTTL(msg); ttl > 0 {
+		task.TTLSeconds = ttl
+		name = "expiring_" + name
+	}
+	name = d.renderName(task, msg, name)
+	// Remember what plain single-file tasks resolved to so the next run of
+	// the same link can skip straight to fetching. Album members are left
+	// out: a cached hit must not shortcut the grouping logic.
+	if cacheableTask(task) {
+		if gid, ok := msg.GetGroupedID(); !ok || gid == 0 {
+			d.locs.put(locationKey(task.Link), cachedLocation{loc: loc, size: size, name: name, channelID: task.channelID})
+		}
+	}
+	return loc, size, name, nil
+}
+
+// mediaTTL returns the TTL of self-destructing media, or 0.
+func mediaTTL(msg *tg.Message) int {
+	media, ok := msg.GetMedia()
+	if !ok {
+		return 0
+	}
+	switch m := media.(type) {
+	case *tg.MessageMediaPhoto:
+		if ttl, ok := m.GetTTLSeconds(); ok {
+			return ttl
+		}
+	case *tg.MessageMediaDocument:
+		if ttl, ok := m.GetTTLSeconds(); ok {
+			return ttl
+		}
+	}
+	return 0
+}
+
+// createDest creates the temporary .part file for an output under destDir,
+// making any subdirectories a filename template produced.
+func createDest(destDir, rel string) (*os.File, error) {
+	path := filepath.Join(destDir, rel) + partSuffix
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
+
+// promotePart atomically renames a finished .part file to its final name.
+func promotePart(destDir, rel string) error {
+	path := filepath.Join(destDir, rel)
+	return os.Rename(path+partSuffix, path)
+}
+
+// partialSuffix marks a deliberate range download: only the first
+// RangeBytes of the file. Distinct from .part (in progress) and the bare
+// name (complete) so nothing mistakes a preview for the whole file.
+const partialSuffix = ".partial"
+
+// promoteRange renames a finished range download to its .partial name.
+func promoteRange(destDir, rel string) error {
+	path := filepath.Join(destDir, rel)
+	return os.Rename(path+partSuffix, path+partialSuffix)
+}
+
+// applyRange clamps task.Size for a task that wants only a leading range
+// of the file, aligning RangeBytes down to the part size so chunk fetches
+// stay aligned. Reports whether it clamped; a file already within the
+// range downloads whole. Call after task.Size is set.
+func applyRange(task *DownloadTask) bool {
+	_, size := task.fileMeta()
+	if task.RangeBytes <= 0 || size <= task.RangeBytes {
+		return false
+	}
+	partSize := int64(partSizeFor(task))
+	n := task.RangeBytes - task.RangeBytes%partSize
+	if n < partSize {
+		n = partSize
+	}
+	task.setSize(n)
+	return true
+}
+
+// fileRef couples a download location with the way to get a fresh one.
+// File references go stale during long downloads (FILE_REFERENCE_EXPIRED);
+// instead of failing, the chunk loop renews the location by re-fetching
+// the message and continues from the same offset.
+type fileRef struct {
+	mu      sync.Mutex
+	loc     tg.InputFileLocationClass
+	refresh func(ctx context.Context) (tg.InputFileLocationClass, error)
+}
+
+func (r *fileRef) location() tg.InputFileLocationClass {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.loc
+}
+
+// renew replaces the stale location. The worker passes the location it
+// just used so that when several workers hit the expiry at once only the
+// first one actually refreshes.
+func (r *fileRef) renew(ctx context.Context, used tg.InputFileLocationClass) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.loc != used {
+		return nil // already refreshed by another worker
+	}
+	if r.refresh == nil {
+		return fmt.Errorf("no refresh available for expired file reference")
+	}
+	loc, err := r.refresh(ctx)
+	if err != nil {
+		return err
+	}
+	r.loc = loc
+	return nil
+}
+
+// Run executes the task to completion, writing the file into task.DestDir.
+// A message that is part of a grouped-media album downloads the whole
+// album as one logical task with combined progress.
+func (d *Downloader) Run(ctx context.Context, task *DownloadTask) error {
+	ctx, cancel := context.WithCancel(ctx)
+	task.setCancel(cancel)
+	defer cancel()
+
+	// Cold-start fast path: a link downloaded before starts from its
+	// cached location, skipping resolution entirely.
+	if cacheableTask(task) {
+		if cached, ok := d.locs.get(locationKey(task.Link)); ok {
+			return d.runCached(ctx, task, cached)
+		}
+	}
+	if task.Link.Peer != "" {
+		return d.runDirect(ctx, task)
+	}
+	ch, err := d.client.ResolveChannel(ctx, task.Link)
+	if err != nil {
+		return d.fail(task, err)
+	}
+	task.channelID = ch.ChannelID
+	if !d.breaker.Allow(ch.ChannelID) {
+		return d.fail(task, fmt.Errorf("channel %d paused by circuit breaker", ch.ChannelID))
+	}
+	msg, err := d.client.GetMessage(ctx, ch, task.Link.MessageID)
+	if err != nil {
+		// A vanished message is not channel ill-health; it neither trips
+		// the breaker nor counts as a failure.
+		var gone *messageGoneError
+		if errors.As(err, &gone) {
+			return d.expire(task, err.Error())
+		}
+		d.breaker.Failure(ch.ChannelID, err)
+		return d.fail(task, err)
+	}
+	if gid, ok := msg.GetGroupedID(); ok && gid != 0 {
+		return d.runAlbum(ctx, task, ch, msg, gid)
+	}
+	if _, hasMedia := msg.GetMedia(); !hasMedia {
+		return d.expire(task, fmt.Sprintf("message %d no longer carries media", msg.ID))
+	}
+
+	loc, size, name, err := d.locationForTask(task, msg)
+	if err != nil {
+		return d.fail(task, err)
+	}
+	task.setMeta(name, size)
+	if size == 0 {
+		if handled, err := d.skipOrFlagEmpty(task); handled {
+			return err
+		}
+	}
+	partial := applyRange(task)
+	_, size = task.fileMeta()
+	task.setStatus("running")
+	task.markStarted()
+	d.emit("task:started", task)
+	logf("download %s: %s (%d bytes)", task.ID, name, size)
+
+	if err := checkDiskSpace(task.DestDir, size); err != nil {
+		return d.fail(task, err)
+	}
+	f, err := createDest(task.DestDir, name)
+	if err != nil {
+		return d.fail(task, err)
+	}
+
+	ref := &fileRef{loc: loc, refresh: d.messageRefresher(task, ch, task.Link.MessageID)}
+	policy := d.policyFor(task)
+	err = d.execute(ctx, task, ref, size, f, 0)
+	if err == nil && policy != durabilityNone {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		if ctx.Err() != nil {
+			return d.interrupted(task)
+		}
+		d.breaker.Failure(ch.ChannelID, err)
+		return d.fail(task, err)
+	}
+	if partial {
+		if err := promoteRange(task.DestDir, name); err != nil {
+			return d.fail(task, err)
+		}
+	} else if err := promotePart(task.DestDir, name); err != nil {
+		return d.fail(task, err)
+	}
+	if policy != durabilityNone {
+		if err := syncDir(task.DestDir); err != nil {
+			logErrorf("download %s: sync %s: %v", task.ID, task.DestDir, err)
+		}
+	}
+
+	d.breaker.Success(ch.ChannelID)
+	// A range download is knowingly incomplete; server hashes would never
+	// match, so verification only applies to whole files.
+	if !partial && (task.verifyWanted() || d.verifyAll || policy == durabilityReadback) {
+		d.verifyStage(ctx, task, ref)
+	}
+	return d.finish(task)
+}
+
+// runDirect executes a task sourced from the account's own message space
+// (Saved Messages, DMs, basic groups). No channel means no circuit
+// breaker and no album grouping; everything else matches the channel
+// path.
+func (d *Downloader) runDirect(ctx context.Context, task *DownloadTask) error {
+	msg, err := d.client.GetMessageDirect(ctx, task.Link.MessageID)
+	if err != nil {
+		var gone *messageGoneError
+		if errors.As(err, &gone) {
+			return d.expire(task, err.Error())
+		}
+		return d.fail(task, err)
+	}
+	if _, hasMedia := msg.GetMedia(); !hasMedia {
+		return d.expire(task, fmt.Sprintf("message %d no longer carries media", msg.ID))
+	}
+	loc, size, name, err := d.locationForTask(task, msg)
+	if err != nil {
+		return d.fail(task, err)
+	}
+	task.setMeta(name, size)
+	if size == 0 {
+		if handled, err := d.skipOrFlagEmpty(task); handled {
+			return err
+		}
+	}
+	partial := applyRange(task)
+	_, size = task.fileMeta()
+	task.setStatus("running")
+	task.markStarted()
+	d.emit("task:started", task)
+	logf("download %s: %s (%d bytes)", task.ID, name, size)
+
+	if err := checkDiskSpace(task.DestDir, size); err != nil {
+		return d.fail(task, err)
+	}
+	f, err := createDest(task.DestDir, name)
+	if err != nil {
+		return d.fail(task, err)
+	}
+
+	ref := &fileRef{loc: loc, refresh: func(ctx context.Context) (tg.InputFileLocationClass, error) {
+		msg, err := d.client.GetMessageDirect(ctx, task.Link.MessageID)
+		if err != nil {
+			return nil, err
+		}
+		loc, _, _, err := d.locationForTask(task, msg)
+		return loc, err
+	}}
+	policy := d.policyFor(task)
+	err = d.execute(ctx, task, ref, size, f, 0)
+	if err == nil && policy != durabilityNone {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		if ctx.Err() != nil {
+			return d.interrupted(task)
+		}
+		return d.fail(task, err)
+	}
+	if partial {
+		if err := promoteRange(task.DestDir, name); err != nil {
+			return d.fail(task, err)
+		}
+	} else if err := promotePart(task.DestDir, name); err != nil {
+		return d.fail(task, err)
+	}
+	if policy != durabilityNone {
+		if err := syncDir(task.DestDir); err != nil {
+			logErrorf("download %s: sync %s: %v", task.ID, task.DestDir, err)
+		}
+	}
+	if !partial && (task.verifyWanted() || d.verifyAll || policy == durabilityReadback) {
+		d.verifyStage(ctx, task, ref)
+	}
+	return d.finish(task)
+}
+
+// messageRefresher builds a fileRef refresh that re-fetches the given
+// message for a fresh file reference.
+func (d *Downloader) messageRefresher(task *DownloadTask, ch *tg.InputChannel, msgID int) func(ctx context.Context) (tg.InputFileLocationClass, error) {
+	return func(ctx context.Context) (tg.InputFileLocationClass, error) {
+		msg, err := d.client.GetMessage(ctx, ch, msgID)
+		if err != nil {
+			return nil, err
+		}
+		loc, _, _, err := d.locationForTask(task, msg)
+		return loc, err
+	}
+}
+
+// tinyFileSize is the cutoff below which a file is fetched with a single
+// request instead of the parallel pipeline. 4 KiB is Telegram's smallest
+// valid limit, so nothing smaller can save a round trip anyway.
+const tinyFileSize = 4 * 1024
+
+// execute transfers the file into f. When the size is known the file is
+// preallocated to it and d.threads workers each fetch a range and write
+// it directly at its offset, so chunk writes no longer serialize behind
+// one stream; sizeless media falls back to the sequential path. Files no
+// larger than tinyFileSize short-circuit to one request.
+func (d *Downloader) execute(ctx context.Context, task *DownloadTask, ref *fileRef, size int64, f *os.File, start int64) error {
+	sampled := make(chan struct{})
+	defer close(sampled)
+	go sampleSpeed(task, sampled)
+	if size <= 0 {
+		if _, err := f.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		return d.streamFrom(ctx, ref, f, task, start)
+	}
+	// A tiny file costs more in worker and monitor setup than in transfer;
+	// one request fetches all of it.
+	if size-start <= tinyFileSize {
+		part, err := d.fetchChunk(ctx, task, ref, start, tinyFileSize)
+		if err != nil {
+			return err
+		}
+		if _, err := f.WriteAt(part.Bytes, start); err != nil {
+			return err
+		}
+		if err := f.Truncate(size); err != nil {
+			return err
+		}
+		atomic.StoreInt64(&task.Written, size)
+		d.emit("task:progress", task)
+		return nil
+	}
+	if err := f.Truncate(size); err != nil {
+		return err
+	}
+	partSize := int64(partSizeFor(task))
+	throttle := d.throttleFor(task)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go monitorDiskSpace(ctx, task)
+
+	offsets := chunkOrder(task, start, size, partSize)
+	workers := d.threads
+	if task.Threads > 0 {
+		workers = task.Threads
+	}
+	if workers > len(offsets) {
+		workers = len(offsets)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	// contig tracks the end of the contiguous completed prefix. When the
+	// transfer stops early the file is truncated back to it, because a
+	// preallocated file with holes would fool Resume's size check.
+	next := 0
+	contig := start
+	done := make(map[int64]bool)
+	var mu sync.Mutex // guards next, contig, done
+	var wg sync.WaitGroup
+	errC := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				if next >= len(offsets) {
+					mu.Unlock()
+					return
+				}
+				off := offsets[next]
+				next++
+				mu.Unlock()
+				part, err := d.fetchChunk(ctx, task, ref, off, int(partSize))
+				if err != nil {
+					errC <- err
+					cancel()
+					return
+				}
+				if throttle != nil {
+					throttle(ctx, len(part.Bytes))
+				}
+				if _, err := f.WriteAt(part.Bytes, off); err != nil {
+					errC <- err
+					cancel()
+					return
+				}
+				mu.Lock()
+				done[off] = true
+				for done[contig] {
+					delete(done, contig)
+					contig += partSize
+				}
+				mu.Unlock()
+				atomic.AddInt64(&task.Written, int64(len(part.Bytes)))
+				d.emit("task:progress", task)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errC)
+
+	// Prefer the root cause over the context errors of workers that were
+	// merely cancelled by it.
+	var firstErr error
+	for err := range errC {
+		if firstErr == nil || (errors.Is(firstErr, context.Canceled) && !errors.Is(err, context.Canceled)) {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		keep := contig
+		if keep > size {
+			keep = size
+		}
+		if err := f.Truncate(keep); err == nil {
+			atomic.StoreInt64(&task.Written, keep)
+		}
+	}
+	return firstErr
+}
+
+// Range-fetch orders for DownloadTask.Strategy.
+const (
+	strategySequential = "sequential"
+	strategyRandom     = "random"
+	strategyRarest     = "rarest"
+)
+
+// chunkOrder lists the part offsets of [start, size) in the order the
+// task's strategy wants them fetched. Sequential keeps the contiguous
+// prefix — and with it the playable head of the file and Resume's anchor —
+// growing as fast as possible. Random scatters the reads, for ISPs that
+// shape long sequential flows differently than scattered ones. Rarest
+// walks back to front, reaching first the ranges that prior runs of a
+// resumed file never got to.
+func chunkOrder(task *DownloadTask, start, size, partSize int64) []int64 {
+	n := int((size - start + partSize - 1) / partSize)
+	if n < 0 {
+		n = 0
+	}
+	offsets := make([]int64, n)
+	for i := range offsets {
+		offsets[i] = start + int64(i)*partSize
+	}
+	switch task.strategy() {
+	case strategyRandom:
+		rand.Shuffle(len(offsets), func(i, j int) {
+			offsets[i], offsets[j] = offsets[j], offsets[i]
+		})
+	case strategyRarest:
+		for i, j := 0, len(offsets)-1; i < j; i, j = i+1, j-1 {
+			offsets[i], offsets[j] = offsets[j], offsets[i]
+		}
+	}
+	return offsets
+}
+
+// albumSpan is how many message IDs around the linked one are fetched
+// when looking for the rest of a grouped-media album. Telegram albums hold
+// at most 10 items, posted with consecutive IDs.
+const albumSpan = 10
+
+// runAlbum downloads every item sharing the message's grouped ID as one
+// logical task: combined size, combined progress, one set of events.
+func (d *Downloader) runAlbum(ctx context.Context, task *DownloadTask, ch *tg.InputChannel, msg *tg.Message, gid int64) error {
+	ids := make([]int, 0, 2*albumSpan+1)
+	for id := msg.ID - albumSpan; id <= msg.ID+albumSpan; id++ {
+		if id > 0 {
+			ids = append(ids, id)
+		}
+	}
+	neighbors, err := d.client.GetMessages(ctx, ch, ids)
+	if err != nil {
+		return d.fail(task, err)
+	}
+
+	type albumItem struct {
+		ref  *fileRef
+		size int64
+		name string
+	}
+	var items []albumItem
+	var total int64
+	for _, m := range neighbors {
+		mgid, ok := m.GetGroupedID()
+		if !ok || mgid != gid {
+			continue
+		}
+		loc, size, name, err := d.locationForTask(task, m)
+		if err != nil {
+			continue // e.g. an album caption-only entry
+		}
+		ref := &fileRef{loc: loc, refresh: d.messageRefresher(task, ch, m.ID)}
+		items = append(items, albumItem{ref: ref, size: size, name: name})
+		total += size
+	}
+	if len(items) == 0 {
+		return d.fail(task, fmt.Errorf("album %d has no downloadable items", gid))
+	}
+
+	task.mu.Lock()
+	task.FileName = fmt.Sprintf("%s (album of %d)", items[0].name, len(items))
+	task.AlbumCount = len(items)
+	task.Size = total
+	task.Status = "running"
+	task.startedAt = time.Now()
+	task.mu.Unlock()
+	d.emit("task:started", task)
+	logf("download %s: album %d with %d items (%d bytes)", task.ID, gid, len(items), total)
+
+	if err := checkDiskSpace(task.DestDir, total); err != nil {
+		return d.fail(task, err)
+	}
+
+	for _, item := range items {
+		f, err := createDest(task.DestDir, item.name)
+		if err != nil {
+			return d.fail(task, err)
+		}
+		err = d.execute(ctx, task, item.ref, item.size, f, 0)
+		f.Close()
+		if err != nil {
+			if ctx.Err() != nil {
+				return d.interrupted(task)
+			}
+			return d.fail(task, err)
+		}
+		if err := promotePart(task.DestDir, item.name); err != nil {
+			return d.fail(task, err)
+		}
+	}
+	return d.finish(task)
+}
+
+// Resume continues a paused task from the bytes already on disk. The write
+// offset is aligned down to the part size and the remaining ranges are
+// fetched in parallel from there.
+func (d *Downloader) Resume(ctx context.Context, task *DownloadTask) error {
+	atomic.StoreInt32(&task.pauseWanted, 0)
+	ctx, cancel := context.WithCancel(ctx)
+	task.setCancel(cancel)
+	defer cancel()
+
+	loc, size, name, err := d.prepare(ctx, task)
+	if err != nil {
+		var gone *messageGoneError
+		if errors.As(err, &gone) {
+			return d.expire(task, err.Error())
+		}
+		return d.fail(task, err)
+	}
+	task.setMeta(name, size)
+	partial := applyRange(task)
+	_, size = task.fileMeta()
+
+	// The .part file is the anchor: a final-named file is complete by
+	// construction (it only appears via the rename), so its absence plus
+	// no .part means starting over.
+	path := filepath.Join(task.DestDir, name) + partSuffix
+	st, err := os.Stat(path)
+	if err != nil {
+		return d.Run(ctx, task)
+	}
+	partSize := int64(partSizeFor(task))
+	offset := st.Size() - st.Size()%partSize
+	if offset > size {
+		offset = size - size%partSize
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0o644)
+	if err != nil {
+		return d.fail(task, err)
+	}
+
+	atomic.StoreInt64(&task.Written, offset)
+	task.setStatus("running")
+	task.markStarted()
+	d.emit("task:started", task)
+	logf("download %s: resuming %s at byte %d", task.ID, name, offset)
+
+	if err := checkDiskSpace(task.DestDir, size-offset); err != nil {
+		return d.fail(task, err)
+	}
+
+	ref := &fileRef{loc: loc, refresh: func(ctx context.Context) (tg.InputFileLocationClass, error) {
+		loc, _, _, err := d.prepare(ctx, task)
+		return loc, err
+	}}
+	policy := d.policyFor(task)
+	err = d.execute(ctx, task, ref, size, f, offset)
+	if err == nil && policy != durabilityNone {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		if ctx.Err() != nil {
+			return d.interrupted(task)
+		}
+		return d.fail(task, err)
+	}
+	if partial {
+		if err := promoteRange(task.DestDir, name); err != nil {
+			return d.fail(task, err)
+		}
+	} else if err := promotePart(task.DestDir, name); err != nil {
+		return d.fail(task, err)
+	}
+	if policy != durabilityNone {
+		if err := syncDir(task.DestDir); err != nil {
+			logErrorf("download %s: sync %s: %v", task.ID, task.DestDir, err)
+		}
+	}
+	if !partial && (task.verifyWanted() || d.verifyAll || policy == durabilityReadback) {
+		d.verifyStage(ctx, task, ref)
+	}
+	return d.finish(task)
+}
+
+// chunkRetries is how many times a single failing range is retried before
+// the error is allowed to take down the task.
+const chunkRetries = 3
+
+// fetchChunk gets one range of the file, retrying transient errors with
+// backoff so a flaky DC connection costs one range a few retries instead
+// of the whole task. An expired file reference is renewed transparently
+// and does not count as a retry.
+func (d *Downloader) fetchChunk(ctx context.Context, task *DownloadTask, ref *fileRef, offset int64, limit int) (*tg.UploadFile, error) {
+	var lastErr error
+	refreshes := 0
+	for attempt := 0; attempt <= chunkRetries; attempt++ {
+		if attempt > 0 {
+			delay := time.Duration(attempt) * 2 * time.Second
+			logWarnf("download %s: chunk at %d failed (%v), retry %d/%d in %s",
+				task.ID, offset, lastErr, attempt, chunkRetries, delay)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		loc := ref.location()
+		res, err := d.client.ChunkAPI().UploadGetFile(ctx, &tg.UploadGetFileRequest{
+			Location: loc,
+			Offset:   offset,
+			Limit:    limit,
+		})
+		if wait, ok := tgerr.AsFloodWait(err); ok {
+			// Waits beyond the middleware's patience land here; surface
+			// the wait on the task instead of burning retries.
+			atomic.AddInt64(&task.floodWaits, 1)
+			d.emit("task:floodwait", map[string]interface{}{
+				"taskId":  task.ID,
+				"seconds": int(wait.Seconds()),
+			})
+			logWarnf("download %s: waiting %s due to rate limit", task.ID, wait)
+			select {
+			case <-time.After(wait + time.Second):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			attempt-- // waiting out a rate limit is not a failure
+			continue
+		}
+		if tgerr.Is(err, "FILE_REFERENCE_EXPIRED") && refreshes < chunkRetries {
+			refreshes++
+			logf("download %s: file reference expired, refreshing", task.ID)
+			if err := ref.renew(ctx, loc); err != nil {
+				return nil, fmt.Errorf("refresh file reference: %w", err)
+			}
+			attempt-- // a successful refresh is not a failure
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			atomic.AddInt64(&task.retries, 1)
+			continue
+		}
+		switch part := res.(type) {
+		case *tg.UploadFile:
+			return part, nil
+		case *tg.UploadFileCDNRedirect:
+			// Popular large files live on CDN caches; fetch the range
+			// there instead, decrypted and hash-checked.
+			data, err := d.fetchCdn(ctx, task, part, offset, limit)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				lastErr = err
+				atomic.AddInt64(&task.retries, 1)
+				continue
+			}
+			return &tg.UploadFile{Bytes: data}, nil
+		default:
+			return nil, fmt.Errorf("unexpected upload.getFile result %T", res)
+		}
+	}
+	return nil, fmt.Errorf("chunk at offset %d: %w", offset, lastErr)
+}
+
+// streamFrom fetches parts sequentially starting at offset, which must be
+// part-size aligned, appending them to f.
+func (d *Downloader) streamFrom(ctx context.Context, ref *fileRef, f *os.File, task *DownloadTask, offset int64) error {
+	throttle := d.throttleFor(task)
+	for {
+		part, err := d.fetchChunk(ctx, task, ref, offset, defaultPartSize)
+		if err != nil {
+			return err
+		}
+		if len(part.Bytes) == 0 {
+			return nil
+		}
+		if throttle != nil {
+			throttle(ctx, len(part.Bytes))
+		}
+		n, err := f.Write(part.Bytes)
+		if err != nil {
+			return err
+		}
+		offset += int64(n)
+		atomic.AddInt64(&task.Written, int64(n))
+		d.emit("task:progress", task)
+		if len(part.Bytes) < defaultPartSize {
+			return nil
+		}
+	}
+}
+
+func (d *Downloader) finish(task *DownloadTask) error {
+	task.closeCdn()
+	task.setStatus("done")
+	report := d.buildReport(task)
+	task.mu.Lock()
+	task.Report = report
+	task.mu.Unlock()
+	d.record(task, "")
+	d.emit("task:done", task)
+	logf("download %s: finished %s", task.ID, task.FileName)
+	return nil
+}
+
+// record logs the task's outcome into the transfer history.
+func (d *Downloader) record(task *DownloadTask, errMsg string) {
+	if d.history == nil {
+		return
+	}
+	rec := TransferRecord{
+		Time:      time.Now(),
+		ChannelID: task.channelID,
+		Bytes:     atomic.LoadInt64(&task.Written),
+		Status:    task.status(),
+		Integrity: task.integrityResult(),
+	}
+	if started := task.started(); !started.IsZero() {
+		rec.Seconds = time.Since(started).Seconds()
+	}
+	if errMsg != "" {
+		rec.ErrorClass = errorClass(errMsg)
+	}
+	d.history.Add(rec)
+}
+
+// interrupted classifies a context cancellation as either a pause (bytes on
+// disk are kept for Resume) or a hard cancel.
+func (d *Downloader) interrupted(task *DownloadTask) error {
+	task.closeCdn()
+	if atomic.LoadInt32(&task.pauseWanted) == 1 {
+		task.setStatus("paused")
+		d.emit("task:paused", task)
+		logf("download %s: paused at %d bytes", task.ID, atomic.LoadInt64(&task.Written))
+		return nil
+	}
+	task.setStatus("cancelled")
+	d.emit("task:cancelled", task)
+	return context.Canceled
+}
+
+// Cancel aborts a running task.
+func (t *DownloadTask) Cancel() {
+	t.mu.Lock()
+	cancel := t.cancel
+	t.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// CancelPost stops the task's post-download verification pass, if one is
+// running; the transfer itself and the finished file are untouched.
+func (t *DownloadTask) CancelPost() bool {
+	t.mu.Lock()
+	cancel := t.postCancel
+	t.mu.Unlock()
+	if cancel == nil {
+		return false
+	}
+	cancel()
+	return true
+}
+
+// Pause stops a running task but keeps its partial file so Resume can pick
+// up from the reached offset.
+func (t *DownloadTask) Pause() {
+	atomic.StoreInt32(&t.pauseWanted, 1)
+	t.mu.Lock()
+	cancel := t.cancel
+	t.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// MarshalJSON locks the task mutex, so a task emitted or persisted while
+// its download runs serializes a consistent view.
+func (t *DownloadTask) MarshalJSON() ([]byte, error) {
+	type plain DownloadTask
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return json.Marshal((*plain)(t))
+}
+
+func (t *DownloadTask) setStatus(status string) {
+	t.mu.Lock()
+	t.Status = status
+	t.mu.Unlock()
+}
+
+func (t *DownloadTask) status() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Status
+}
+
+func (t *DownloadTask) setError(msg string) {
+	t.mu.Lock()
+	t.Error = msg
+	t.mu.Unlock()
+}
+
+func (t *DownloadTask) errText() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Error
+}
+
+func (t *DownloadTask) setMeta(name string, size int64) {
+	t.mu.Lock()
+	t.FileName, t.Size = name, size
+	t.mu.Unlock()
+}
+
+func (t *DownloadTask) setSize(size int64) {
+	t.mu.Lock()
+	t.Size = size
+	t.mu.Unlock()
+}
+
+func (t *DownloadTask) fileMeta() (name string, size int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.FileName, t.Size
+}
+
+func (t *DownloadTask) setIntegrity(outcome string) {
+	t.mu.Lock()
+	t.Integrity = outcome
+	t.mu.Unlock()
+}
+
+func (t *DownloadTask) integrityResult() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Integrity
+}
+
+func (t *DownloadTask) flagSuspicious() {
+	t.mu.Lock()
+	t.Suspicious = true
+	t.mu.Unlock()
+}
+
+func (t *DownloadTask) suspicious() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Suspicious
+}
+
+func (t *DownloadTask) markStarted() {
+	t.mu.Lock()
+	t.startedAt = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *DownloadTask) started() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.startedAt
+}
+
+func (t *DownloadTask) setDeadline(deadline time.Time) {
+	t.mu.Lock()
+	t.Deadline = deadline
+	t.mu.Unlock()
+}
+
+func (t *DownloadTask) deadlineTime() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Deadline
+}
+
+func (t *DownloadTask) startTime() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.StartAt
+}
+
+func (t *DownloadTask) report() *AccelReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Report
+}
+
+func (t *DownloadTask) speedLimit() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.LimitBytesPerSec
+}
+
+func (t *DownloadTask) setStrategy(strategy string) {
+	t.mu.Lock()
+	t.Strategy = strategy
+	t.mu.Unlock()
+}
+
+func (t *DownloadTask) strategy() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Strategy
+}
+
+func (t *DownloadTask) setVerify(on bool) {
+	t.mu.Lock()
+	t.Verify = on
+	t.mu.Unlock()
+}
+
+func (t *DownloadTask) verifyWanted() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Verify
+}
+
+func (t *DownloadTask) setCancel(cancel context.CancelFunc) {
+	t.mu.Lock()
+	t.cancel = cancel
+	t.mu.Unlock()
+}
+
+func (t *DownloadTask) setPostCancel(cancel context.CancelFunc) {
+	t.mu.Lock()
+	t.postCancel = cancel
+	t.mu.Unlock()
+}
+
+// expire marks a task whose source vanished between queueing and start:
+// the message was deleted, or edited to no longer carry media. Distinct
+// from "failed" so the UI offers cleanup instead of a pointless retry.
+func (d *Downloader) expire(task *DownloadTask, reason string) error {
+	task.closeCdn()
+	task.setStatus("expired")
+	task.setError(reason)
+	d.record(task, reason)
+	d.emit("task:expired", task)
+	logWarnf("download %s expired: %s", task.ID, reason)
+	return errors.New(reason)
+}
+
+func (d *Downloader) fail(task *DownloadTask, err error) error {
+	task.closeCdn()
+	task.setStatus("failed")
+	task.setError(err.Error())
+	d.record(task, err.Error())
+	d.emit("task:failed", task)
+	logErrorf("download %s failed: %v", task.ID, err)
+	return err
+}
+
+// mediaLocation extracts the downloadable file location from a message,
+// along with its size and a filename to save it under.
+func mediaLocation(msg *tg.Message) (tg.InputFileLocationClass, int64, string, error) {
+	media, ok := msg.GetMedia()
+	if !ok {
+		return nil, 0, "", fmt.Errorf("message %d has no media", msg.ID)
+	}
+	switch m := media.(type) {
+	case *tg.MessageMediaDocument:
+		doc, ok := m.Document.AsNotEmpty()
+		if !ok {
+			return nil, 0, "", fmt.Errorf("message %d: empty document", msg.ID)
+		}
+		name := documentFileName(doc)
+		if name == "" {
+			name = fmt.Sprintf("file_%d", msg.ID)
+		}
+		return &tg.InputDocumentFileLocation{
+			ID:            doc.ID,
+			AccessHash:    doc.AccessHash,
+			FileReference: doc.FileReference,
+		}, doc.Size, name, nil
+	case *tg.MessageMediaPhoto:
+		photo, ok := m.Photo.AsNotEmpty()
+		if !ok {
+			return nil, 0, "", fmt.Errorf("message %d: empty photo", msg.ID)
+		}
+		thumb, size := largestPhotoSize(photo)
+		if thumb == "" {
+			return nil, 0, "", fmt.Errorf("message %d: photo has no sizes", msg.ID)
+		}
+		return &tg.InputPhotoFileLocation{
+			ID:            photo.ID,
+			AccessHash:    photo.AccessHash,
+			FileReference: photo.FileReference,
+			ThumbSize:     thumb,
+		}, size, fmt.Sprintf("photo_%d.jpg", msg.ID), nil
+	default:
+		return nil, 0, "", fmt.Errorf("message %d: unsupported media %T", msg.ID, media)
+	}
+}
+
+func documentFileName(doc *tg.Document) string {
+	for _, attr := range doc.Attributes {
+		if a, ok := attr.(*tg.DocumentAttributeFilename); ok {
+			return a.FileName
+		}
+	}
+	return ""
+}
+
+func largestPhotoSize(photo *tg.Photo) (string, int64) {
+	var thumb string
+	var size int64
+	for _, s := range photo.Sizes {
+		switch ps := s.(type) {
+		case *tg.PhotoSize:
+			if int64(ps.Size) > size {
+				thumb, size = ps.Type, int64(ps.Size)
+			}
+		case *tg.PhotoSizeProgressive:
+			// Progressive sizes list cumulative byte counts per quality
+			// level; the last one is the full image.
+			for _, n := range ps.Sizes {
+				if int64(n) > size {
+					thumb, size = ps.Type, int64(n)
+				}
+			}
+		}
+	}
+	return thumb, size
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// Durability policies, configured per destination directory. Local SSDs
+// are fine with the OS cache ("none"); network shares and USB drives,
+// where silent truncation on unplug or disconnect is common, warrant
+// fsync on finalize or a full read-back against the server's hashes.
+const (
+	durabilityNone     = "none"
+	durabilityFsync    = "fsync"
+	durabilityReadback = "readback"
+)
+
+// SetDurabilityPolicy configures how files finishing under destDir are
+// pushed to disk: "fsync" syncs file and directory on finalize,
+// "readback" additionally re-reads the file and verifies it against
+// Telegram's part hashes, and "none" removes the policy. Subdirectories
+// inherit the policy of the longest configured prefix.
+func (a *App) SetDurabilityPolicy(destDir, policy string) Response {
+	switch policy {
+	case durabilityNone, durabilityFsync, durabilityReadback:
+	default:
+		return respErr(CodeInvalidArgument, "policy must be none, fsync or readback")
+	}
+	if destDir == "" {
+		return respErr(CodeInvalidArgument, "destination directory required")
+	}
+	a.mu.Lock()
+	if a.durability == nil {
+		a.durability = make(map[string]string)
+	}
+	if policy == durabilityNone {
+		delete(a.durability, destDir)
+	} else {
+		a.durability[destDir] = policy
+	}
+	a.mu.Unlock()
+	a.audit.Record("settings", "durability "+policy+" for "+destDir)
+	return respOK()
+}
+
+// GetDurabilityPolicies returns the configured destination policies.
+func (a *App) GetDurabilityPolicies() map[string]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make(map[string]string, len(a.durability))
+	for dir, policy := range a.durability {
+		out[dir] = policy
+	}
+	return out
+}
+
+// durabilityFor resolves the policy for one destination; the longest
+// configured prefix wins, so a policy on a mount point covers everything
+// under it.
+func (a *App) durabilityFor(destDir string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	policy := durabilityNone
+	best := -1
+	for dir, p := range a.durability {
+		if dir == destDir || strings.HasPrefix(destDir, strings.TrimRight(dir, "/")+"/") {
+			if len(dir) > best {
+				policy, best = p, len(dir)
+			}
+		}
+	}
+	return policy
+}
+
+// syncDir fsyncs a directory so a just-renamed file's entry survives
+// power loss, completing the .part promotion durably.
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
@@ -0,0 +1,13 @@
+//go:build !headless
+
+package main
+
+import "github.com/wailsapp/wails/v2/pkg/runtime"
+
+// emitEvent delivers an event to the Wails frontend. Before startup ran
+// there is no Wails context yet and events are dropped.
+func (a *App) emitEvent(event string, data interface{}) {
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, event, data)
+	}
+}
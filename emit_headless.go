@@ -0,0 +1,15 @@
+//go:build headless
+
+package main
+
+// eventSink is set by the API server; events emitted before it is up are
+// dropped, matching the desktop build's pre-startup behavior. The emitting
+// App rides along so profile events stay within their profile.
+var eventSink func(app *App, event string, data interface{})
+
+// emitEvent forwards an event to the REST /events stream.
+func (a *App) emitEvent(event string, data interface{}) {
+	if eventSink != nil {
+		eventSink(a, event, data)
+	}
+}
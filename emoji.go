@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// customEmojiBatch is the per-call ID limit of
+// messages.getCustomEmojiDocuments.
+const customEmojiBatch = 100
+
+// EmojiEntry records one exported custom emoji document.
+type EmojiEntry struct {
+	DocumentID int64  `json:"documentId"`
+	FileName   string `json:"fileName"`
+	Alt        string `json:"alt"`
+	MimeType   string `json:"mimeType"`
+}
+
+// EmojiManifest is written next to the exported emoji files.
+type EmojiManifest struct {
+	ChannelID  int64        `json:"channelId"`
+	ExportedAt time.Time    `json:"exportedAt"`
+	Entries    []EmojiEntry `json:"entries"`
+}
+
+// ExportCustomEmoji scans the channel's history for custom emoji used in
+// messages, downloads each referenced document once and writes an
+// emoji-manifest.json describing the set.
+func (e *ChannelExporter) ExportCustomEmoji(ctx context.Context, link *LinkInfo, dir string) (int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+	ch, err := e.client.ResolveChannel(ctx, link)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := e.collectEmojiIDs(ctx, ch)
+	if err != nil {
+		return 0, err
+	}
+
+	manifest := &EmojiManifest{ChannelID: ch.ChannelID, ExportedAt: time.Now()}
+	for start := 0; start < len(ids); start += customEmojiBatch {
+		end := start + customEmojiBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		docs, err := e.client.API().MessagesGetCustomEmojiDocuments(ctx, ids[start:end])
+		if err != nil {
+			return len(manifest.Entries), fmt.Errorf("get custom emoji documents: %w", err)
+		}
+		for _, d := range docs {
+			doc, ok := d.(*tg.Document)
+			if !ok {
+				continue
+			}
+			entry := EmojiEntry{
+				DocumentID: doc.ID,
+				FileName:   fmt.Sprintf("emoji_%d%s", doc.ID, emojiExt(doc.MimeType)),
+				Alt:        customEmojiAlt(doc),
+				MimeType:   doc.MimeType,
+			}
+			if err := e.downloader.SaveDocument(ctx, doc, dir, entry.FileName); err != nil {
+				return len(manifest.Entries), fmt.Errorf("emoji %d: %w", doc.ID, err)
+			}
+			manifest.Entries = append(manifest.Entries, entry)
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return len(manifest.Entries), err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "emoji-manifest.json"), data, 0o644); err != nil {
+		return len(manifest.Entries), err
+	}
+	return len(manifest.Entries), nil
+}
+
+// collectEmojiIDs walks the channel history and gathers the distinct
+// custom emoji document IDs referenced by message entities.
+func (e *ChannelExporter) collectEmojiIDs(ctx context.Context, ch *tg.InputChannel) ([]int64, error) {
+	peer := &tg.InputPeerChannel{ChannelID: ch.ChannelID, AccessHash: ch.AccessHash}
+	seen := make(map[int64]bool)
+	offsetID := 0
+	for {
+		res, err := e.client.API().MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+			Peer:     peer,
+			OffsetID: offsetID,
+			Limit:    100,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get history: %w", err)
+		}
+		msgs, ok := res.(*tg.MessagesChannelMessages)
+		if !ok {
+			return nil, fmt.Errorf("unexpected history type %T", res)
+		}
+		if len(msgs.Messages) == 0 {
+			break
+		}
+		progressed := false
+		for _, m := range msgs.Messages {
+			msg, ok := m.(*tg.Message)
+			if !ok {
+				continue
+			}
+			offsetID = msg.ID
+			progressed = true
+			for _, ent := range msg.Entities {
+				if ce, ok := ent.(*tg.MessageEntityCustomEmoji); ok {
+					seen[ce.DocumentID] = true
+				}
+			}
+		}
+		if !progressed {
+			break
+		}
+	}
+	ids := make([]int64, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func customEmojiAlt(doc *tg.Document) string {
+	for _, attr := range doc.Attributes {
+		if a, ok := attr.(*tg.DocumentAttributeCustomEmoji); ok {
+			return a.Alt
+		}
+	}
+	return ""
+}
+
+func emojiExt(mime string) string {
+	switch mime {
+	case "application/x-tgsticker":
+		return ".tgs"
+	case "video/webm":
+		return ".webm"
+	default:
+		return ".webp"
+	}
+}
@@ -0,0 +1,191 @@
+//go:build headless
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsEvent is one event on the WebSocket stream. Seq is a per-profile
+// cursor: a client that reconnects with ?cursor=<last seen seq> resumes
+// exactly where it left off, as long as the ring still covers that point.
+type wsEvent struct {
+	Seq   uint64      `json:"seq"`
+	Event string      `json:"event"`
+	Data  interface{} `json:"data"`
+}
+
+const (
+	// wsRingSize is how many events are retained for resuming clients.
+	wsRingSize = 512
+	// wsSendBuffer is the per-client queue; a client that falls this far
+	// behind is disconnected and told to resume from its cursor, so one
+	// slow browser tab never stalls event delivery to the rest.
+	wsSendBuffer   = 64
+	wsWriteTimeout = 10 * time.Second
+)
+
+// eventRing retains recent events under monotonically increasing sequence
+// numbers, one ring per profile App.
+type eventRing struct {
+	mu   sync.Mutex
+	seq  uint64
+	ring []wsEvent
+}
+
+func (r *eventRing) add(event string, data interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	r.ring = append(r.ring, wsEvent{Seq: r.seq, Event: event, Data: data})
+	if len(r.ring) > wsRingSize {
+		r.ring = r.ring[len(r.ring)-wsRingSize:]
+	}
+}
+
+// since returns the buffered events after cursor and whether the cursor
+// was still inside the ring. False means the client is too far behind to
+// replay seamlessly and should refetch its full state first.
+func (r *eventRing) since(cursor uint64) ([]wsEvent, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cursor > r.seq {
+		cursor = r.seq
+	}
+	missed := r.seq - cursor
+	if missed > uint64(len(r.ring)) {
+		return append([]wsEvent(nil), r.ring...), false
+	}
+	return append([]wsEvent(nil), r.ring[uint64(len(r.ring))-missed:]...), true
+}
+
+// Cross-origin connects are fine: access is gated by the API token, and
+// a web frontend will not be served from this origin.
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// ringFor returns the event ring of an App, creating it on first use.
+func (s *apiServer) ringFor(app *App) *eventRing {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ring, ok := s.rings[app]
+	if !ok {
+		ring = &eventRing{}
+		s.rings[app] = ring
+	}
+	return ring
+}
+
+// publishWS records an event in its App's ring and fans it out to that
+// App's WebSocket clients. A client whose queue is full gets its channel
+// closed, which the write loop turns into a "try again later" close frame
+// carrying the resume instruction.
+func (s *apiServer) publishWS(src *App, event string, data interface{}) {
+	s.ringFor(src).add(event, data)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seq := s.rings[src].seq
+	for ch, app := range s.wsSubs {
+		if app != src {
+			continue
+		}
+		select {
+		case ch <- wsEvent{Seq: seq, Event: event, Data: data}:
+		default:
+			delete(s.wsSubs, ch)
+			close(ch)
+		}
+	}
+}
+
+// handleWS streams events over a WebSocket, the richer sibling of the SSE
+// /events endpoint: sequence numbers make the stream resumable, and
+// backpressure disconnects slow clients instead of dropping their events
+// silently.
+func (s *apiServer) handleWS(w http.ResponseWriter, r *http.Request) {
+	app := s.appFor(r)
+	if app == nil {
+		http.Error(w, "unknown or missing API token", http.StatusUnauthorized)
+		return
+	}
+	var cursor uint64
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "cursor must be a sequence number", http.StatusBadRequest)
+			return
+		}
+		cursor = n
+	}
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// Register before computing the backlog so no event can fall between
+	// replay and live delivery; duplicates are skipped by sequence number.
+	ch := make(chan wsEvent, wsSendBuffer)
+	s.mu.Lock()
+	s.wsSubs[ch] = app
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.wsSubs, ch)
+		s.mu.Unlock()
+	}()
+
+	backlog, contiguous := s.ringFor(app).since(cursor)
+	var lastSent uint64
+	if !contiguous {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := conn.WriteJSON(wsEvent{Event: "stream:reset"}); err != nil {
+			return
+		}
+	}
+	for _, ev := range backlog {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := conn.WriteJSON(ev); err != nil {
+			return
+		}
+		lastSent = ev.Seq
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				deadline := time.Now().Add(wsWriteTimeout)
+				msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater,
+					"event buffer overflow; reconnect with your last cursor")
+				conn.WriteControl(websocket.CloseMessage, msg, deadline)
+				return
+			}
+			if ev.Seq <= lastSent {
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+			lastSent = ev.Seq
+		case <-done:
+			return
+		}
+	}
+}
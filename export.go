@@ -0,0 +1,494 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+const (
+	manifestName   = "manifest.json"
+	diffNamePrefix = "diff-"
+)
+
+// ExportEntry records one media message inside a channel export.
+type ExportEntry struct {
+	MessageID int    `json:"messageId"`
+	FileName  string `json:"fileName"`
+	Size      int64  `json:"size"`
+	Caption   string `json:"caption"`
+	Date      int    `json:"date"`
+}
+
+// ExportManifest is written next to the exported files and is the baseline
+// the next sync of the same channel diffs against.
+type ExportManifest struct {
+	ChannelID int64         `json:"channelId"`
+	SyncedAt  time.Time     `json:"syncedAt"`
+	Entries   []ExportEntry `json:"entries"`
+	// PrunedIDs lists messages whose local files were removed by
+	// retention; later syncs must not re-download them.
+	PrunedIDs []int `json:"prunedIds,omitempty"`
+}
+
+// RetentionPolicy bounds how much a continuously synced folder may hold.
+// Zero values disable the respective limit.
+type RetentionPolicy struct {
+	KeepLast   int   `json:"keepLast"`   // keep only the newest N files
+	MaxAgeDays int   `json:"maxAgeDays"` // delete files older than this
+	MaxBytes   int64 `json:"maxBytes"`   // cap the folder's total media size
+}
+
+func (p RetentionPolicy) enabled() bool {
+	return p.KeepLast > 0 || p.MaxAgeDays > 0 || p.MaxBytes > 0
+}
+
+// ExportDiff describes what changed in a channel between two syncs of the
+// same export directory.
+type ExportDiff struct {
+	GeneratedAt    time.Time     `json:"generatedAt"`
+	PreviousSync   time.Time     `json:"previousSync"`
+	Added          []ExportEntry `json:"added"`
+	CaptionChanged []ExportEntry `json:"captionChanged"`
+	Deleted        []ExportEntry `json:"deleted"`
+}
+
+// Empty reports whether the diff carries no changes.
+func (d *ExportDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.CaptionChanged) == 0 && len(d.Deleted) == 0
+}
+
+// Failure policies for sync jobs: what an item-level download error does
+// to the rest of the run.
+const (
+	failSkip  = "skip"  // record the item and continue (default)
+	failPause = "pause" // stop the run; StartJob resumes and retries
+	failAbort = "abort" // fail the whole job
+)
+
+// errPausedOnFailure is returned by Sync under the pause policy so the
+// caller can park the job as paused rather than failed.
+var errPausedOnFailure = errors.New("paused after item failure")
+
+// FailedItem records one message a sync could not download. Failed items
+// stay absent locally, so the next run of the job retries exactly them.
+type FailedItem struct {
+	MessageID int    `json:"messageId"`
+	FileName  string `json:"fileName"`
+	Error     string `json:"error"`
+}
+
+// ChannelExporter mirrors a channel's media into a local directory and keeps
+// a manifest so repeated syncs only fetch what is new.
+type ChannelExporter struct {
+	client     *TGClient
+	downloader *Downloader
+	// useTakeout routes the bulk history walks through the account's
+	// takeout session when one was granted; see SetUseTakeout.
+	useTakeout bool
+}
+
+// NewChannelExporter builds an exporter on top of a connected client.
+func NewChannelExporter(client *TGClient, dl *Downloader) *ChannelExporter {
+	return &ChannelExporter{client: client, downloader: dl}
+}
+
+// SetUseTakeout toggles routing bulk calls through the takeout session.
+func (e *ChannelExporter) SetUseTakeout(on bool) {
+	e.useTakeout = on
+}
+
+// api picks the raw client for bulk history walks: the takeout-wrapped
+// one when the toggle is on and a session was granted, the regular one
+// otherwise — so the toggle is safe to leave on before consent arrives.
+func (e *ChannelExporter) api() *tg.Client {
+	if e.useTakeout {
+		if api := e.client.TakeoutAPI(); api != nil {
+			return api
+		}
+	}
+	return e.client.API()
+}
+
+// Sync mirrors the channel into dir. When the directory already holds a
+// manifest from an earlier run, the returned diff lists new files, changed
+// captions and deleted messages since that run, and the same report is
+// saved alongside the export as diff-<timestamp>.json. The retention
+// policy, if any, is applied after the sync so the folder stays bounded.
+// Observe, when non-nil, is told about every task the sync creates, so
+// job tracking can list child tasks. An item that fails to download is
+// handled per the failure policy (failSkip when empty) and reported in
+// the returned list either way.
+func (e *ChannelExporter) Sync(ctx context.Context, link *LinkInfo, dir string, policy RetentionPolicy, failure string, observe func(*DownloadTask)) (*ExportDiff, []FailedItem, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, err
+	}
+	prev, err := loadManifest(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	pruned := make(map[int]bool)
+	if prev != nil {
+		for _, id := range prev.PrunedIDs {
+			pruned[id] = true
+		}
+	}
+
+	ch, err := e.client.ResolveChannel(ctx, link)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := e.collectEntries(ctx, ch, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var failed []FailedItem
+	for _, entry := range entries {
+		if pruned[entry.MessageID] {
+			continue // removed locally by retention; don't re-fetch
+		}
+		if _, err := os.Stat(filepath.Join(dir, entry.FileName)); err == nil {
+			continue
+		}
+		task := &DownloadTask{
+			ID:      fmt.Sprintf("export-%d-%d", ch.ChannelID, entry.MessageID),
+			Link:    &LinkInfo{ChannelID: ch.ChannelID, MessageID: entry.MessageID, Private: true},
+			DestDir: dir,
+			Status:  "queued",
+		}
+		if observe != nil {
+			observe(task)
+		}
+		if err := e.downloader.Run(ctx, task); err != nil {
+			if ctx.Err() != nil {
+				return nil, failed, ctx.Err()
+			}
+			failed = append(failed, FailedItem{MessageID: entry.MessageID, FileName: entry.FileName, Error: err.Error()})
+			switch failure {
+			case failAbort:
+				return nil, failed, fmt.Errorf("export message %d: %w", entry.MessageID, err)
+			case failPause:
+				return nil, failed, fmt.Errorf("export message %d: %v: %w", entry.MessageID, err, errPausedOnFailure)
+			default: // failSkip: the file stays absent, the next run retries
+				logWarnf("export message %d: %v (skipped)", entry.MessageID, err)
+			}
+		}
+	}
+
+	manifest := &ExportManifest{ChannelID: ch.ChannelID, SyncedAt: time.Now(), Entries: entries}
+	// Carry forward pruned IDs that still exist in the channel.
+	for _, entry := range entries {
+		if pruned[entry.MessageID] {
+			manifest.PrunedIDs = append(manifest.PrunedIDs, entry.MessageID)
+		}
+	}
+	if policy.enabled() {
+		if err := applyRetention(dir, manifest, policy); err != nil {
+			return nil, failed, err
+		}
+	}
+	if err := writeManifest(dir, manifest); err != nil {
+		return nil, failed, err
+	}
+
+	diff := diffManifests(prev, manifest)
+	if prev != nil {
+		if err := writeDiff(dir, diff); err != nil {
+			return nil, failed, err
+		}
+	}
+	return diff, failed, nil
+}
+
+// LastDiff returns the most recent saved diff report in an export
+// directory, or nil when no sync has produced one yet.
+func LastDiff(dir string) (*ExportDiff, error) {
+	names, err := filepath.Glob(filepath.Join(dir, diffNamePrefix+"*.json"))
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+	sort.Strings(names)
+	data, err := os.ReadFile(names[len(names)-1])
+	if err != nil {
+		return nil, err
+	}
+	var diff ExportDiff
+	if err := json.Unmarshal(data, &diff); err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+// applyRetention deletes local files so the folder honours the policy,
+// recording the pruned message IDs in the manifest. Deletion order is
+// oldest first; the newest files always survive longest.
+func applyRetention(dir string, m *ExportManifest, policy RetentionPolicy) error {
+	alreadyPruned := make(map[int]bool, len(m.PrunedIDs))
+	for _, id := range m.PrunedIDs {
+		alreadyPruned[id] = true
+	}
+	// Local files, oldest message first (entries are sorted ascending).
+	var local []ExportEntry
+	for _, e := range m.Entries {
+		if !alreadyPruned[e.MessageID] {
+			local = append(local, e)
+		}
+	}
+
+	drop := make(map[int]bool)
+	if policy.MaxAgeDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -policy.MaxAgeDays).Unix()
+		for _, e := range local {
+			if int64(e.Date) < cutoff {
+				drop[e.MessageID] = true
+			}
+		}
+	}
+	if policy.KeepLast > 0 && len(local) > policy.KeepLast {
+		for _, e := range local[:len(local)-policy.KeepLast] {
+			drop[e.MessageID] = true
+		}
+	}
+	if policy.MaxBytes > 0 {
+		var total int64
+		for _, e := range local {
+			if !drop[e.MessageID] {
+				total += e.Size
+			}
+		}
+		for _, e := range local {
+			if total <= policy.MaxBytes {
+				break
+			}
+			if !drop[e.MessageID] {
+				drop[e.MessageID] = true
+				total -= e.Size
+			}
+		}
+	}
+
+	for _, e := range local {
+		if !drop[e.MessageID] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.FileName)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("retention: remove %s: %w", e.FileName, err)
+		}
+		m.PrunedIDs = append(m.PrunedIDs, e.MessageID)
+	}
+	sort.Ints(m.PrunedIDs)
+	return nil
+}
+
+func (e *ChannelExporter) collectEntries(ctx context.Context, ch *tg.InputChannel, dir string) ([]ExportEntry, error) {
+	peer := &tg.InputPeerChannel{ChannelID: ch.ChannelID, AccessHash: ch.AccessHash}
+	var entries []ExportEntry
+	offsetID := 0
+	for {
+		res, err := e.api().MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+			Peer:     peer,
+			OffsetID: offsetID,
+			Limit:    100,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get history: %w", err)
+		}
+		msgs, ok := res.(*tg.MessagesChannelMessages)
+		if !ok {
+			return nil, fmt.Errorf("unexpected history type %T", res)
+		}
+		if len(msgs.Messages) == 0 {
+			break
+		}
+		progressed := false
+		for _, m := range msgs.Messages {
+			msg, ok := m.(*tg.Message)
+			if !ok {
+				continue
+			}
+			offsetID = msg.ID
+			progressed = true
+			name, size, ok := e.describeMessage(msg, dir)
+			if !ok {
+				continue // no exportable media
+			}
+			entries = append(entries, ExportEntry{
+				MessageID: msg.ID,
+				FileName:  name,
+				Size:      size,
+				Caption:   msg.Message,
+				Date:      msg.Date,
+			})
+		}
+		if !progressed {
+			// A page of nothing but service messages would otherwise leave
+			// offsetID where it was and re-request the same page forever.
+			break
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].MessageID < entries[j].MessageID })
+	return entries, nil
+}
+
+// describeMessage returns the export filename and size for a message.
+// Contacts and locations have no downloadable file; they are written as
+// .vcf / .gpx sidecars right here so full-channel exports don't fail on
+// non-file media.
+func (e *ChannelExporter) describeMessage(msg *tg.Message, dir string) (string, int64, bool) {
+	media, hasMedia := msg.GetMedia()
+	if !hasMedia {
+		return "", 0, false
+	}
+	switch m := media.(type) {
+	case *tg.MessageMediaContact:
+		name := fmt.Sprintf("contact_%d.vcf", msg.ID)
+		if err := writeContactVCF(filepath.Join(dir, name), m); err != nil {
+			logWarnf("export: contact %d: %v", msg.ID, err)
+			return "", 0, false
+		}
+		return name, fileSize(dir, name), true
+	case *tg.MessageMediaGeo:
+		if point, ok := m.Geo.(*tg.GeoPoint); ok {
+			name := fmt.Sprintf("location_%d.gpx", msg.ID)
+			if err := writeGeoGPX(filepath.Join(dir, name), point, ""); err != nil {
+				logWarnf("export: location %d: %v", msg.ID, err)
+				return "", 0, false
+			}
+			return name, fileSize(dir, name), true
+		}
+		return "", 0, false
+	case *tg.MessageMediaVenue:
+		if point, ok := m.Geo.(*tg.GeoPoint); ok {
+			name := fmt.Sprintf("venue_%d.gpx", msg.ID)
+			if err := writeGeoGPX(filepath.Join(dir, name), point, m.Title); err != nil {
+				logWarnf("export: venue %d: %v", msg.ID, err)
+				return "", 0, false
+			}
+			return name, fileSize(dir, name), true
+		}
+		return "", 0, false
+	default:
+		_, size, name, err := mediaLocation(msg)
+		if err != nil {
+			return "", 0, false
+		}
+		return name, size, true
+	}
+}
+
+func fileSize(dir, name string) int64 {
+	st, err := os.Stat(filepath.Join(dir, name))
+	if err != nil {
+		return 0
+	}
+	return st.Size()
+}
+
+// writeContactVCF saves a shared contact, preferring the vCard Telegram
+// already carries and synthesizing a minimal one otherwise.
+func writeContactVCF(path string, m *tg.MessageMediaContact) error {
+	card := m.Vcard
+	if card == "" {
+		card = fmt.Sprintf("BEGIN:VCARD\r\nVERSION:3.0\r\nFN:%s %s\r\nTEL:%s\r\nEND:VCARD\r\n",
+			m.FirstName, m.LastName, m.PhoneNumber)
+	}
+	return os.WriteFile(path, []byte(card), 0o644)
+}
+
+// writeGeoGPX saves a geo point as a single-waypoint GPX file.
+func writeGeoGPX(path string, point *tg.GeoPoint, title string) error {
+	// Venue titles are arbitrary text; escape them or an "&" in the name
+	// breaks the document.
+	var name bytes.Buffer
+	if err := xml.EscapeText(&name, []byte(title)); err != nil {
+		return err
+	}
+	gpx := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="TeleTurbo" xmlns="http://www.topografix.com/GPX/1/1">
+  <wpt lat="%f" lon="%f">
+    <name>%s</name>
+  </wpt>
+</gpx>
+`, point.Lat, point.Long, name.String())
+	return os.WriteFile(path, []byte(gpx), 0o644)
+}
+
+// diffManifests compares two manifests by message ID. A nil prev yields a
+// diff where everything currently present counts as added.
+func diffManifests(prev, cur *ExportManifest) *ExportDiff {
+	diff := &ExportDiff{GeneratedAt: time.Now()}
+	if prev != nil {
+		diff.PreviousSync = prev.SyncedAt
+	}
+
+	prevByID := make(map[int]ExportEntry)
+	if prev != nil {
+		for _, e := range prev.Entries {
+			prevByID[e.MessageID] = e
+		}
+	}
+	curIDs := make(map[int]bool, len(cur.Entries))
+	for _, e := range cur.Entries {
+		curIDs[e.MessageID] = true
+		old, existed := prevByID[e.MessageID]
+		switch {
+		case !existed:
+			diff.Added = append(diff.Added, e)
+		case old.Caption != e.Caption:
+			diff.CaptionChanged = append(diff.CaptionChanged, e)
+		}
+	}
+	if prev != nil {
+		for _, e := range prev.Entries {
+			if !curIDs[e.MessageID] {
+				diff.Deleted = append(diff.Deleted, e)
+			}
+		}
+		sort.Slice(diff.Deleted, func(i, j int) bool {
+			return diff.Deleted[i].MessageID < diff.Deleted[j].MessageID
+		})
+	}
+	return diff
+}
+
+func loadManifest(dir string) (*ExportManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m ExportManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("corrupt manifest in %s: %w", dir, err)
+	}
+	return &m, nil
+}
+
+func writeManifest(dir string, m *ExportManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, manifestName), data, 0o644)
+}
+
+func writeDiff(dir string, d *ExportDiff) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := diffNamePrefix + d.GeneratedAt.UTC().Format("20060102T150405Z") + ".json"
+	return os.WriteFile(filepath.Join(dir, name), data, 0o644)
+}
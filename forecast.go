@@ -0,0 +1,79 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// TaskForecast projects when one pending or running task will finish.
+type TaskForecast struct {
+	TaskID         string    `json:"taskId"`
+	RemainingBytes int64     `json:"remainingBytes"`
+	ETA            time.Time `json:"eta"`
+}
+
+// QueueForecast projects the whole queue from the current aggregate speed.
+// Unknown sizes count as zero remaining, so the projection is a lower
+// bound when the queue holds unprobed links.
+type QueueForecast struct {
+	SpeedBytesPerSec float64        `json:"speedBytesPerSec"`
+	RemainingBytes   int64          `json:"remainingBytes"`
+	QueueDoneAt      time.Time      `json:"queueDoneAt"`
+	Tasks            []TaskForecast `json:"tasks"`
+}
+
+// GetQueueForecast estimates when each unfinished task and the queue as a
+// whole will complete. The aggregate speed is measured between successive
+// calls from the bytes written since the last one, so the first call (and
+// an idle queue) yields no ETAs; the projection assumes the queue drains
+// in its current order at that speed.
+func (a *App) GetQueueForecast() QueueForecast {
+	state := a.queue.State()
+	now := time.Now()
+
+	var written int64
+	for _, task := range append(append([]*DownloadTask{}, state.Active...), state.Queued...) {
+		written += atomic.LoadInt64(&task.Written)
+	}
+	for _, task := range state.Finished {
+		written += atomic.LoadInt64(&task.Written)
+	}
+
+	a.mu.Lock()
+	var speed float64
+	if !a.forecastAt.IsZero() {
+		if dt := now.Sub(a.forecastAt).Seconds(); dt > 0 && written >= a.forecastWritten {
+			speed = float64(written-a.forecastWritten) / dt
+		}
+	}
+	a.forecastAt = now
+	a.forecastWritten = written
+	a.mu.Unlock()
+
+	forecast := QueueForecast{SpeedBytesPerSec: speed}
+	var cumulative int64
+	project := func(task *DownloadTask) {
+		_, size := task.fileMeta()
+		remaining := size - atomic.LoadInt64(&task.Written)
+		if remaining < 0 {
+			remaining = 0
+		}
+		cumulative += remaining
+		tf := TaskForecast{TaskID: task.ID, RemainingBytes: remaining}
+		if speed > 0 {
+			tf.ETA = now.Add(time.Duration(float64(cumulative) / speed * float64(time.Second)))
+		}
+		forecast.Tasks = append(forecast.Tasks, tf)
+	}
+	for _, task := range state.Active {
+		project(task)
+	}
+	for _, task := range state.Queued {
+		project(task)
+	}
+	forecast.RemainingBytes = cumulative
+	if speed > 0 {
+		forecast.QueueDoneAt = now.Add(time.Duration(float64(cumulative) / speed * float64(time.Second)))
+	}
+	return forecast
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// formatPrefs controls how numbers are rendered for the user: which
+// locale's decimal separator to use and whether sizes are binary (KiB,
+// 1024-based) or SI (kB, 1000-based).
+type formatPrefs struct {
+	locale string
+	binary bool
+}
+
+// commaLocales are the locales TeleTurbo renders with a decimal comma;
+// everything else gets a period.
+var commaLocales = map[string]bool{
+	"de": true, "fr": true, "es": true, "it": true, "pt": true,
+	"ru": true, "uk": true, "tr": true, "pl": true, "nl": true,
+}
+
+func (p formatPrefs) decimalSep() string {
+	lang := p.locale
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	if commaLocales[strings.ToLower(lang)] {
+		return ","
+	}
+	return "."
+}
+
+var (
+	siUnits     = []string{"B", "kB", "MB", "GB", "TB"}
+	binaryUnits = []string{"B", "KiB", "MiB", "GiB", "TiB"}
+)
+
+// formatBytes renders a byte count like "1.4 GB" or "1,3 GiB" depending
+// on the preferences.
+func formatBytes(n int64, p formatPrefs) string {
+	step := 1000.0
+	units := siUnits
+	if p.binary {
+		step = 1024.0
+		units = binaryUnits
+	}
+	value := float64(n)
+	unit := 0
+	for value >= step && unit < len(units)-1 {
+		value /= step
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", n, units[0])
+	}
+	s := fmt.Sprintf("%.1f", value)
+	s = strings.Replace(s, ".", p.decimalSep(), 1)
+	return s + " " + units[unit]
+}
+
+// formatSpeed renders bytes per second like "2.5 MB/s".
+func formatSpeed(bytesPerSec float64, p formatPrefs) string {
+	return formatBytes(int64(bytesPerSec), p) + "/s"
+}
+
+// formatDuration renders a second count in at most two units, like
+// "2h 05m" or "45s", which is plenty for an ETA.
+func formatDuration(seconds float64) string {
+	if seconds < 0 {
+		return ""
+	}
+	s := int64(seconds + 0.5)
+	switch {
+	case s < 60:
+		return fmt.Sprintf("%ds", s)
+	case s < 3600:
+		return fmt.Sprintf("%dm %02ds", s/60, s%60)
+	case s < 86400:
+		return fmt.Sprintf("%dh %02dm", s/3600, s%3600/60)
+	default:
+		return fmt.Sprintf("%dd %dh", s/86400, s%86400/3600)
+	}
+}
+
+// SetFormatPreferences sets the locale (e.g. "en", "de-DE") and unit
+// system (binary KiB vs SI kB) used by the formatting bindings.
+func (a *App) SetFormatPreferences(locale string, binary bool) Response {
+	a.mu.Lock()
+	a.format = formatPrefs{locale: locale, binary: binary}
+	a.mu.Unlock()
+	return respOK()
+}
+
+func (a *App) formatPrefs() formatPrefs {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.format
+}
+
+// FormatBytes renders a byte count per the user's format preferences, so
+// every frontend reports sizes the same way.
+func (a *App) FormatBytes(n int64) string {
+	return formatBytes(n, a.formatPrefs())
+}
+
+// FormatSpeed renders a bytes-per-second rate per the user's preferences.
+func (a *App) FormatSpeed(bytesPerSec float64) string {
+	return formatSpeed(bytesPerSec, a.formatPrefs())
+}
+
+// FormatDuration renders a second count as a compact ETA string.
+func (a *App) FormatDuration(seconds float64) string {
+	return formatDuration(seconds)
+}
@@ -0,0 +1,120 @@
+//go:build headless
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// healthCheckTimeout bounds the Telegram round-trip in /readyz so a dead
+// connection turns into a fast 503 instead of a hanging probe.
+const healthCheckTimeout = 5 * time.Second
+
+var serverStarted = time.Now()
+
+// healthReport is the JSON body of /healthz and /readyz.
+type healthReport struct {
+	Status        string            `json:"status"` // "ok" or "degraded"
+	Version       string            `json:"version"`
+	UptimeSeconds int64             `json:"uptimeSeconds"`
+	Checks        map[string]string `json:"checks,omitempty"` // check name -> "ok" or the failure
+	// ClockSkewSeconds is how far the system clock is ahead of Telegram's
+	// (negative: behind); only present when /readyz could measure it.
+	ClockSkewSeconds *int64 `json:"clockSkewSeconds,omitempty"`
+}
+
+// handleHealthz reports liveness: the process is up and serving.
+func (s *apiServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, healthReport{
+		Status:        "ok",
+		Version:       appVersion,
+		UptimeSeconds: int64(time.Since(serverStarted).Seconds()),
+	})
+}
+
+// handleReadyz reports readiness: Telegram is reachable, the session is
+// authorized, and the download destination is writable. Any failure turns
+// into a 503 so orchestrators stop routing work here.
+func (s *apiServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{
+		"telegram": "ok",
+		"auth":     "ok",
+		"storage":  "ok",
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+	connected := false
+	var skewSeconds *int64
+	for _, app := range s.apps() {
+		name, client := app.accounts.Active()
+		if client == nil {
+			continue
+		}
+		connected = true
+		authorized, err := client.Authorized(ctx)
+		switch {
+		case err != nil:
+			checks["telegram"] = err.Error()
+			checks["auth"] = "unknown: " + err.Error()
+		case !authorized:
+			checks["auth"] = "account " + name + " is not signed in"
+		}
+		// A wrong system clock is the classic cause of the auth errors
+		// above, so measure and report it alongside them.
+		if skewSeconds == nil && err == nil && authorized {
+			if skew, err := client.ClockSkew(ctx); err == nil {
+				sec := int64(skew.Seconds())
+				skewSeconds = &sec
+				if skew > clockSkewLimit || skew < -clockSkewLimit {
+					checks["clock"] = "system clock is off by " + skew.String()
+				}
+			}
+		}
+	}
+	if !connected {
+		checks["telegram"] = "no account connected"
+		checks["auth"] = "no account connected"
+	}
+	if err := checkStorageWritable(); err != nil {
+		checks["storage"] = err.Error()
+	}
+
+	report := healthReport{
+		Status:           "ok",
+		Version:          appVersion,
+		UptimeSeconds:    int64(time.Since(serverStarted).Seconds()),
+		Checks:           checks,
+		ClockSkewSeconds: skewSeconds,
+	}
+	status := http.StatusOK
+	for _, result := range checks {
+		if result != "ok" {
+			report.Status = "degraded"
+			status = http.StatusServiceUnavailable
+			break
+		}
+	}
+	writeJSON(w, status, report)
+}
+
+// checkStorageWritable proves the download destination (or the config dir
+// when none is set) accepts writes, catching read-only or full volumes.
+func checkStorageWritable() error {
+	dir := overrides.DestDir
+	if dir == "" {
+		var err error
+		if dir, err = configDir(); err != nil {
+			return err
+		}
+	}
+	probe := filepath.Join(dir, ".teleturbo-writecheck")
+	if err := os.WriteFile(probe, []byte("ok"), 0o644); err != nil {
+		return err
+	}
+	return os.Remove(probe)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// heartbeatInterval is how often each account's authorization is verified
+// in the background. Sessions die quietly — revoked from another device,
+// expired, the account deactivated — and nothing else notices until the
+// next real call fails.
+const heartbeatInterval = 5 * time.Minute
+
+// watchSessions periodically verifies every connected account's
+// authorization with a lightweight self-call and emits auth:invalid the
+// moment one stops being signed in, so the UI flips its logged-in state
+// instead of finding out on the user's next download.
+func (a *App) watchSessions(ctx context.Context) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	alive := make(map[string]bool)
+	for {
+		select {
+		case <-ticker.C:
+			a.checkSessions(ctx, alive)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkSessions probes each bundle once; alive carries the last known
+// state per account so only transitions to dead emit events. A transport
+// error is not a dead session — the next tick retries instead of alarming
+// on a network blip.
+func (a *App) checkSessions(ctx context.Context, alive map[string]bool) {
+	a.mu.Lock()
+	bundles := make(map[string]*accountBundle, len(a.bundles))
+	for name, b := range a.bundles {
+		bundles[name] = b
+	}
+	a.mu.Unlock()
+
+	for name, b := range bundles {
+		probeCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		authorized, err := b.client.Authorized(probeCtx)
+		cancel()
+		if err != nil {
+			continue
+		}
+		if !authorized && alive[name] {
+			a.audit.Record("auth:invalid", name)
+			a.emit("auth:invalid", name)
+		}
+		alive[name] = authorized
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Job is a persistent channel sync operation: unlike one-off downloads it
+// survives restarts and can be paused, resumed and inspected from the UI.
+type Job struct {
+	ID        string          `json:"id"`
+	Link      string          `json:"link"`
+	DestDir   string          `json:"destDir"`
+	Account   string          `json:"account"`
+	Retention RetentionPolicy `json:"retention"`
+	// FailurePolicy is what an item-level download error does to the run:
+	// failSkip (default), failPause or failAbort.
+	FailurePolicy string `json:"failurePolicy,omitempty"`
+	// FailedItems lists the messages the last run could not download.
+	// Their files stay absent, so the next StartJob retries exactly them.
+	FailedItems []FailedItem `json:"failedItems,omitempty"`
+	Status      string       `json:"status"` // created, running, paused, done, failed
+	Error       string       `json:"error"`
+	TaskIDs     []string     `json:"taskIds"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	UpdatedAt   time.Time    `json:"updatedAt"`
+
+	cancel context.CancelFunc
+}
+
+// JobStore keeps jobs in memory and mirrors them to jobs.json under the
+// app config dir so long exports survive restarts.
+// jobsName is the state document holding the job list.
+const jobsName = "jobs.json"
+
+type JobStore struct {
+	mu     sync.Mutex
+	store  stateStore
+	jobs   map[string]*Job
+	nextID int
+}
+
+// NewJobStore loads any previously persisted jobs from the given state
+// directory. Jobs that were running when the app died come back as paused
+// so the user can resume them.
+func NewJobStore(dir string) (*JobStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("no state directory")
+	}
+	s := &JobStore{
+		store: stateStoreFor(dir),
+		jobs:  make(map[string]*Job),
+	}
+	data, err := s.store.Load(jobsName)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return s, nil
+	}
+	var jobs []*Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("corrupt jobs file: %w", err)
+	}
+	for _, job := range jobs {
+		if job.Status == "running" {
+			job.Status = "paused"
+		}
+		s.jobs[job.ID] = job
+		if n := jobNumber(job.ID); n > s.nextID {
+			s.nextID = n
+		}
+	}
+	return s, nil
+}
+
+func jobNumber(id string) int {
+	var n int
+	fmt.Sscanf(id, "job-%d", &n)
+	return n
+}
+
+// Create adds and persists a new job.
+func (s *JobStore) Create(link, destDir, account string, retention RetentionPolicy, failurePolicy string) *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	job := &Job{
+		ID:            fmt.Sprintf("job-%d", s.nextID),
+		Link:          link,
+		DestDir:       destDir,
+		Account:       account,
+		Retention:     retention,
+		FailurePolicy: failurePolicy,
+		Status:        "created",
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	s.jobs[job.ID] = job
+	s.saveLocked()
+	return job
+}
+
+// Get returns a job by ID, or nil.
+func (s *JobStore) Get(id string) *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[id]
+}
+
+// List returns all jobs sorted by ID.
+func (s *JobStore) List() []*Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobNumber(jobs[i].ID) < jobNumber(jobs[j].ID) })
+	return jobs
+}
+
+// Delete cancels a job if running and removes it permanently.
+func (s *JobStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return fmt.Errorf("no job %s", id)
+	}
+	if job.cancel != nil {
+		job.cancel()
+	}
+	delete(s.jobs, id)
+	s.saveLocked()
+	return nil
+}
+
+// Update applies a mutation under the store lock and persists the result.
+func (s *JobStore) Update(id string, mutate func(*Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return
+	}
+	mutate(job)
+	job.UpdatedAt = time.Now()
+	s.saveLocked()
+}
+
+func (s *JobStore) saveLocked() {
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobNumber(jobs[i].ID) < jobNumber(jobs[j].ID) })
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := s.store.Store(jobsName, data); err != nil {
+		logErrorf("jobs: save: %v", err)
+	}
+}
+
+// CreateSyncJob registers a persistent sync job without starting it.
+// failurePolicy picks what an item failure does to a run — failSkip ("" or
+// "skip", the default), "pause" or "abort".
+func (a *App) CreateSyncJob(link, destDir string, retention RetentionPolicy, failurePolicy string) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	switch failurePolicy {
+	case "", failSkip, failPause, failAbort:
+	default:
+		return respErr(CodeInvalidArgument, "unknown failure policy "+failurePolicy)
+	}
+	if _, err := ParseLink(link); err != nil {
+		return respError(err)
+	}
+	job := a.jobs.Create(link, destDir, b.name, retention, failurePolicy)
+	return respID(job.ID)
+}
+
+// StartJob runs (or resumes, since syncs are idempotent) a job in the
+// background.
+func (a *App) StartJob(id string) Response {
+	job := a.jobs.Get(id)
+	if job == nil {
+		return respErr(CodeNotFound, "no job "+id)
+	}
+	if job.Status == "running" {
+		return respErr(CodeConflict, "job already running")
+	}
+	b := a.bundleFor(job.Account)
+	if b == nil {
+		return respErr(CodeNotConnected, "job account is not connected")
+	}
+	info, err := ParseLink(job.Link)
+	if err != nil {
+		return respError(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.jobs.Update(id, func(j *Job) {
+		j.Status = "running"
+		j.Error = ""
+		j.FailedItems = nil
+		j.cancel = cancel
+	})
+	go func() {
+		observe := func(task *DownloadTask) {
+			a.tasks.Add(task)
+			a.jobs.Update(id, func(j *Job) {
+				j.TaskIDs = append(j.TaskIDs, task.ID)
+			})
+		}
+		_, failed, err := b.exporter.Sync(ctx, info, job.DestDir, job.Retention, job.FailurePolicy, observe)
+		a.jobs.Update(id, func(j *Job) {
+			j.cancel = nil
+			j.FailedItems = failed
+			switch {
+			case ctx.Err() != nil:
+				j.Status = "paused"
+			case errors.Is(err, errPausedOnFailure):
+				// The pause policy parks the job; the failed item stays
+				// absent locally so the next StartJob retries it.
+				j.Status = "paused"
+				j.Error = err.Error()
+			case err != nil:
+				j.Status = "failed"
+				j.Error = err.Error()
+			default:
+				j.Status = "done"
+			}
+		})
+	}()
+	return respOK()
+}
+
+// PauseJob stops a running job; already-downloaded files stay and a later
+// StartJob picks up where it left off.
+func (a *App) PauseJob(id string) Response {
+	job := a.jobs.Get(id)
+	if job == nil {
+		return respErr(CodeNotFound, "no job "+id)
+	}
+	if job.cancel == nil {
+		return respErr(CodeConflict, "job is not running")
+	}
+	job.cancel()
+	return respOK()
+}
+
+// DeleteJob removes a job permanently, cancelling it first if needed.
+// Downloaded files are left on disk.
+func (a *App) DeleteJob(id string) Response {
+	if err := a.jobs.Delete(id); err != nil {
+		return respError(err)
+	}
+	return respOK()
+}
+
+// GetJob returns one job with its progress and child task IDs.
+func (a *App) GetJob(id string) *Job {
+	return a.jobs.Get(id)
+}
+
+// ListJobs returns all persistent jobs.
+func (a *App) ListJobs() []*Job {
+	return a.jobs.List()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket over bytes per second. A zero rate means
+// unlimited and Wait returns immediately.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   int64 // bytes per second, 0 = unlimited
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate int64) *rateLimiter {
+	return &rateLimiter{rate: rate, last: time.Now()}
+}
+
+// SetRate changes the limit; the bucket is reset so the new rate applies
+// promptly.
+func (l *rateLimiter) SetRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.tokens = 0
+	l.last = time.Now()
+}
+
+// Rate returns the configured limit.
+func (l *rateLimiter) Rate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// Wait blocks until n bytes fit in the budget or ctx is cancelled.
+func (l *rateLimiter) Wait(ctx context.Context, n int) {
+	for {
+		l.mu.Lock()
+		if l.rate <= 0 {
+			l.mu.Unlock()
+			return
+		}
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+		if cap := float64(l.rate); l.tokens > cap {
+			l.tokens = cap // at most one second of burst
+		}
+		l.last = now
+		if l.tokens >= float64(n) {
+			l.tokens -= float64(n)
+			l.mu.Unlock()
+			return
+		}
+		missing := float64(n) - l.tokens
+		delay := time.Duration(missing / float64(l.rate) * float64(time.Second))
+		l.mu.Unlock()
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
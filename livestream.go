@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/gotd/td/tg"
+)
+
+// LiveStreamEvent is emitted when a group video chat / live stream starts
+// in a watched channel. RTMP fields are filled when the account is allowed
+// to read the stream credentials (typically channel admins).
+type LiveStreamEvent struct {
+	ChannelID  int64  `json:"channelId"`
+	CallID     int64  `json:"callId"`
+	AccessHash int64  `json:"accessHash"`
+	RtmpURL    string `json:"rtmpUrl"`
+	RtmpKey    string `json:"rtmpKey"`
+}
+
+// handleServiceMessage inspects channel service messages for group call
+// starts and hands live streams off to the configured recorder.
+func (w *Watcher) handleServiceMessage(ctx context.Context, channelID int64, msg *tg.MessageService) {
+	action, ok := msg.Action.(*tg.MessageActionGroupCall)
+	if !ok {
+		return
+	}
+	call, ok := action.Call.(*tg.InputGroupCall)
+	if !ok {
+		return
+	}
+	watched := false
+	for _, rule := range w.Rules() {
+		if rule.matchesChannel(channelID) {
+			watched = true
+			break
+		}
+	}
+	if !watched {
+		return
+	}
+
+	event := LiveStreamEvent{
+		ChannelID:  channelID,
+		CallID:     call.ID,
+		AccessHash: call.AccessHash,
+	}
+	// Stream credentials are only readable with admin rights; treat
+	// failure as "detection only". The peer needs a real access hash, so
+	// resolve through the channel cache first.
+	if ch, err := w.client.ResolveChannel(ctx, &LinkInfo{ChannelID: channelID, Private: true}); err == nil {
+		if url, err := w.client.API().PhoneGetGroupCallStreamRtmpURL(ctx, &tg.PhoneGetGroupCallStreamRtmpURLRequest{
+			Peer: &tg.InputPeerChannel{ChannelID: ch.ChannelID, AccessHash: ch.AccessHash},
+		}); err == nil {
+			event.RtmpURL = url.URL
+			event.RtmpKey = url.Key
+		}
+	}
+
+	w.app.emit("watch:livestream", event)
+	logf("watch: live stream started in %d (call %d)", channelID, call.ID)
+
+	if cmd := w.recorderCommand(); cmd != "" {
+		go runRecorder(ctx, cmd, event)
+	}
+}
+
+// runRecorder invokes the external recorder hand-off with the stream
+// details in its environment.
+func runRecorder(ctx context.Context, command string, event LiveStreamEvent) {
+	cmd := exec.CommandContext(ctx, command)
+	cmd.Env = append(os.Environ(),
+		"TELETURBO_CHANNEL_ID="+strconv.FormatInt(event.ChannelID, 10),
+		"TELETURBO_CALL_ID="+strconv.FormatInt(event.CallID, 10),
+		"TELETURBO_CALL_ACCESS_HASH="+strconv.FormatInt(event.AccessHash, 10),
+		"TELETURBO_RTMP_URL="+event.RtmpURL,
+		"TELETURBO_RTMP_KEY="+event.RtmpKey,
+	)
+	if err := cmd.Run(); err != nil {
+		logErrorf("watch: recorder %q failed: %v", command, err)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gotd/td/tg"
+)
+
+// cachedLocation remembers how a message's media was fetched last time:
+// enough to start a re-download without resolving the peer or re-fetching
+// the message. File references go stale, so this is only a fast path —
+// the chunk loop's renew falls back to full resolution when the server
+// rejects the cached reference.
+type cachedLocation struct {
+	loc       tg.InputFileLocationClass
+	size      int64
+	name      string
+	channelID int64
+}
+
+type locationCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedLocation
+}
+
+func newLocationCache() *locationCache {
+	return &locationCache{entries: make(map[string]cachedLocation)}
+}
+
+// locationKey identifies a message across every link form the parser
+// produces.
+func locationKey(link *LinkInfo) string {
+	return fmt.Sprintf("%s:%d:%s:%d:%d", link.Peer, link.PeerID, link.Username, link.ChannelID, link.MessageID)
+}
+
+func (c *locationCache) get(key string) (cachedLocation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+func (c *locationCache) put(key string, entry cachedLocation) {
+	c.mu.Lock()
+	c.entries[key] = entry
+	c.mu.Unlock()
+}
+
+// cacheableTask reports whether a task's media location may be reused
+// across runs: plain single-file fetches only, since variants, quality
+// picks, templates and expiring media all change what prepare returns.
+func cacheableTask(task *DownloadTask) bool {
+	return task.Variant == 0 && task.Quality == "" && task.NameTemplate == "" && task.TTLSeconds == 0
+}
+
+// runCached starts a re-download straight from cached metadata, skipping
+// peer resolution and the message fetch that dominate start latency for
+// repeat operations. A stale file reference costs one renew round trip —
+// the fileRef falls back to the full prepare path — instead of failing.
+func (d *Downloader) runCached(ctx context.Context, task *DownloadTask, c cachedLocation) error {
+	task.channelID = c.channelID
+	task.setMeta(c.name, c.size)
+	if c.size == 0 {
+		if handled, err := d.skipOrFlagEmpty(task); handled {
+			return err
+		}
+	}
+	partial := applyRange(task)
+	_, size := task.fileMeta()
+	task.setStatus("running")
+	task.markStarted()
+	d.emit("task:started", task)
+	logf("download %s: %s (%d bytes, cached metadata)", task.ID, c.name, size)
+
+	if err := checkDiskSpace(task.DestDir, size); err != nil {
+		return d.fail(task, err)
+	}
+	f, err := createDest(task.DestDir, c.name)
+	if err != nil {
+		return d.fail(task, err)
+	}
+
+	ref := &fileRef{loc: c.loc, refresh: func(ctx context.Context) (tg.InputFileLocationClass, error) {
+		loc, _, _, err := d.prepare(ctx, task)
+		return loc, err
+	}}
+	policy := d.policyFor(task)
+	err = d.execute(ctx, task, ref, size, f, 0)
+	if err == nil && policy != durabilityNone {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		if ctx.Err() != nil {
+			return d.interrupted(task)
+		}
+		var gone *messageGoneError
+		if errors.As(err, &gone) {
+			return d.expire(task, err.Error())
+		}
+		return d.fail(task, err)
+	}
+	if partial {
+		if err := promoteRange(task.DestDir, c.name); err != nil {
+			return d.fail(task, err)
+		}
+	} else if err := promotePart(task.DestDir, c.name); err != nil {
+		return d.fail(task, err)
+	}
+	if policy != durabilityNone {
+		if err := syncDir(task.DestDir); err != nil {
+			logErrorf("download %s: sync %s: %v", task.ID, task.DestDir, err)
+		}
+	}
+	if !partial && (task.verifyWanted() || d.verifyAll || policy == durabilityReadback) {
+		d.verifyStage(ctx, task, ref)
+	}
+	return d.finish(task)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Log files live under <config dir>/logs and rotate by size so a
+// long-running headless instance cannot fill the disk.
+const (
+	logFileName = "teleturbo.log"
+	logFileMax  = 5 << 20 // rotate after 5 MiB
+	logFileKeep = 3       // teleturbo.log.1 .. teleturbo.log.3
+)
+
+// logLevel is the runtime-adjustable threshold shared by every handler;
+// SetLogLevel changes it without rebuilding the logger.
+var logLevel = new(slog.LevelVar)
+
+// logger is the app-wide structured logger. Until initLogging runs it
+// writes to stdout only; afterwards it also appends to the rotating file.
+var logger = slog.New(slog.NewTextHandler(stdoutWriter{}, &slog.HandlerOptions{Level: logLevel}))
+
+// stdoutWriter resolves os.Stdout at write time, so log lines keep flowing
+// into the capture pipe after startLogCapture re-points os.Stdout.
+type stdoutWriter struct{}
+
+func (stdoutWriter) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+
+// initLogging adds the rotating log file under the config dir as a second
+// sink. Called once overrides are parsed; lines logged before that go to
+// stdout only.
+func initLogging() {
+	base, err := configDir()
+	if err != nil {
+		return
+	}
+	dir := filepath.Join(base, "logs")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		logWarnf("logging: %v (file logging disabled)", err)
+		return
+	}
+	sink := io.MultiWriter(stdoutWriter{}, &rotatingFile{path: filepath.Join(dir, logFileName)})
+	logger = slog.New(slog.NewTextHandler(sink, &slog.HandlerOptions{Level: logLevel}))
+}
+
+// rotatingFile is a size-rotated append-only sink: when the current file
+// would pass logFileMax it is shifted to .1 (older files move up, the one
+// past logFileKeep is dropped) and a fresh file is started. Write never
+// returns an error — a broken log file must not take the stdout sink down
+// with it through io.MultiWriter.
+type rotatingFile struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	size int64
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f != nil && r.size+int64(len(p)) > logFileMax {
+		r.f.Close()
+		r.f = nil
+		for i := logFileKeep - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		os.Rename(r.path, r.path+".1")
+	}
+	if r.f == nil {
+		f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return len(p), nil
+		}
+		if st, err := f.Stat(); err == nil {
+			r.size = st.Size()
+		} else {
+			r.size = 0
+		}
+		r.f = f
+	}
+	n, _ := r.f.Write(p)
+	r.size += int64(n)
+	return len(p), nil
+}
+
+// logf, logWarnf and logErrorf are the printf-style entry points the rest
+// of the app logs through: info for routine progress, warn for degraded
+// but recoverable conditions, error for failures. Every line is redacted
+// before it reaches any sink.
+func logf(format string, args ...interface{}) { logEmit(slog.LevelInfo, format, args) }
+
+func logWarnf(format string, args ...interface{}) { logEmit(slog.LevelWarn, format, args) }
+
+func logErrorf(format string, args ...interface{}) { logEmit(slog.LevelError, format, args) }
+
+func logDebugf(format string, args ...interface{}) { logEmit(slog.LevelDebug, format, args) }
+
+func logEmit(level slog.Level, format string, args []interface{}) {
+	logger.Log(context.Background(), level, redactLine(fmt.Sprintf(format, args...)))
+}
+
+// logPhonePattern requires the leading +, unlike the broader bundle
+// redaction in crashreport.go, so byte counts and message IDs in ordinary
+// log lines survive.
+var logPhonePattern = regexp.MustCompile(`\+\d{7,15}`)
+
+// redactLine strips the sensitive values that can plausibly appear in log
+// lines: bot-style tokens and international phone numbers.
+func redactLine(s string) string {
+	s = tokenPattern.ReplaceAllString(s, "[token]")
+	return logPhonePattern.ReplaceAllString(s, "[phone]")
+}
+
+// SetLogLevel changes the logger threshold at runtime: "debug", "info",
+// "warn" or "error".
+func (a *App) SetLogLevel(level string) Response {
+	switch strings.ToLower(level) {
+	case "debug":
+		logLevel.Set(slog.LevelDebug)
+	case "info":
+		logLevel.Set(slog.LevelInfo)
+	case "warn":
+		logLevel.Set(slog.LevelWarn)
+	case "error":
+		logLevel.Set(slog.LevelError)
+	default:
+		return respErr(CodeInvalidArgument, "unknown log level "+level)
+	}
+	return respOK()
+}
+
+// GetRecentLogs returns up to limit recent log lines, oldest first, for
+// the debug panel. Lines get the same redaction support bundles do.
+func (a *App) GetRecentLogs(limit int) []string {
+	lines := recentLogs.tail()
+	if limit > 0 && len(lines) > limit {
+		lines = lines[len(lines)-limit:]
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = redact(line)
+	}
+	return out
+}
@@ -0,0 +1,45 @@
+//go:build !headless
+
+package main
+
+import (
+	"embed"
+	"log"
+
+	"github.com/wailsapp/wails/v2"
+	"github.com/wailsapp/wails/v2/pkg/options"
+	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
+)
+
+//go:embed all:frontend/dist
+var assets embed.FS
+
+func main() {
+	defer reportPanic()
+	startLogCapture()
+	loadOverrides()
+	initLogging()
+	migrateConfig()
+
+	app := NewApp("")
+
+	err := wails.Run(&options.App{
+		Title:            "TeleTurbo",
+		Width:            960,
+		Height:           640,
+		MinWidth:         720,
+		MinHeight:        480,
+		BackgroundColour: &options.RGBA{R: 17, G: 17, B: 23, A: 1},
+		AssetServer: &assetserver.Options{
+			Assets: assets,
+		},
+		OnStartup:  app.startup,
+		OnShutdown: app.shutdown,
+		Bind: []interface{}{
+			app,
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
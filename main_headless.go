@@ -0,0 +1,73 @@
+//go:build headless
+
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// The headless build (go build -tags headless) runs the download engine
+// plus a REST API without any GUI dependency, for containers and NAS
+// boxes; with a subcommand (login, download, queue) it acts as a one-shot
+// CLI tool instead. Configuration comes from flags and TELETURBO_*
+// environment variables; session and downloads live wherever -config-dir
+// and -dest-dir point, typically bind-mounted volumes.
+func main() {
+	defer reportPanic()
+	startLogCapture()
+	loadOverrides()
+	initLogging()
+	migrateConfig()
+
+	// A subcommand turns the binary into a one-shot CLI tool; see cli.go.
+	if args := flag.Args(); len(args) > 0 {
+		os.Exit(runCLI(args))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	profiles, err := loadProfiles()
+	if err != nil {
+		logErrorf("profiles: %v", err)
+		os.Exit(1)
+	}
+	var srv *apiServer
+	if len(profiles) > 0 {
+		apps, err := buildProfileApps(profiles)
+		if err != nil {
+			logErrorf("profiles: %v", err)
+			os.Exit(1)
+		}
+		for _, app := range apps {
+			app.startup(ctx)
+		}
+		srv = newProfileAPIServer(apps)
+		logf("serving %d isolated profiles", len(profiles))
+	} else {
+		app := NewApp("")
+		app.startup(ctx)
+		srv = newAPIServer(app)
+	}
+	go func() {
+		if err := srv.listen(overrides.Listen); err != nil {
+			logErrorf("server: %v", err)
+			cancel()
+		}
+	}()
+	logf("headless server listening on %s", overrides.Listen)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-stop:
+	case <-ctx.Done():
+	}
+	cancel()
+	for _, app := range srv.apps() {
+		app.shutdown(ctx)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// MediaItem is one file found while browsing a channel's media, enough to
+// render a selectable list and queue downloads from it.
+type MediaItem struct {
+	MessageID int       `json:"messageId"`
+	FileName  string    `json:"fileName"`
+	Size      int64     `json:"size"`
+	Date      time.Time `json:"date"`
+	Caption   string    `json:"caption"`
+}
+
+// searchFilter maps a browser filter name to the messages.search filter.
+func searchFilter(filter string) (tg.MessagesFilterClass, error) {
+	switch filter {
+	case "", "all":
+		return &tg.InputMessagesFilterEmpty{}, nil
+	case "documents":
+		return &tg.InputMessagesFilterDocument{}, nil
+	case "video":
+		return &tg.InputMessagesFilterVideo{}, nil
+	case "audio":
+		return &tg.InputMessagesFilterMusic{}, nil
+	case "photos":
+		return &tg.InputMessagesFilterPhotos{}, nil
+	default:
+		return nil, fmt.Errorf("unknown media filter %q", filter)
+	}
+}
+
+// searchPage runs one messages.search call, sleeping out any FLOOD_WAIT
+// the server imposes, mirroring getHistoryPage.
+func searchPage(ctx context.Context, api *tg.Client, req *tg.MessagesSearchRequest) (*tg.MessagesChannelMessages, error) {
+	for {
+		res, err := api.MessagesSearch(ctx, req)
+		if wait, ok := tgerr.AsFloodWait(err); ok {
+			logWarnf("search: flood wait %s", wait)
+			select {
+			case <-time.After(wait + time.Second):
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("search: %w", err)
+		}
+		msgs, ok := res.(*tg.MessagesChannelMessages)
+		if !ok {
+			return nil, fmt.Errorf("unexpected search type %T", res)
+		}
+		return msgs, nil
+	}
+}
+
+// ChannelMedia pages through a channel's media of one kind, newest first.
+// offsetID continues from a previous page (pass the last item's message
+// ID); zero starts from the top. query narrows by text, "" matches all.
+func (c *TGClient) ChannelMedia(ctx context.Context, link *LinkInfo, query, filter string, offsetID, limit int) ([]MediaItem, error) {
+	f, err := searchFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+	ch, err := c.ResolveChannel(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := searchPage(ctx, c.API(), &tg.MessagesSearchRequest{
+		Peer:     &tg.InputPeerChannel{ChannelID: ch.ChannelID, AccessHash: ch.AccessHash},
+		Q:        query,
+		Filter:   f,
+		OffsetID: offsetID,
+		Limit:    limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	items := make([]MediaItem, 0, len(msgs.Messages))
+	for _, m := range msgs.Messages {
+		msg, ok := m.(*tg.Message)
+		if !ok {
+			continue
+		}
+		item := MediaItem{
+			MessageID: msg.ID,
+			Date:      time.Unix(int64(msg.Date), 0),
+			Caption:   msg.Message,
+		}
+		if _, size, name, err := mediaLocation(msg); err == nil {
+			item.Size = size
+			item.FileName = name
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// GetChannelMedia browses a channel's files for in-app selection. filter
+// is one of "documents", "video", "audio", "photos" or "" for everything;
+// offsetID pages by passing the previous page's last message ID.
+func (a *App) GetChannelMedia(channel, filter string, offsetID, limit int) []MediaItem {
+	b := a.active()
+	if b == nil {
+		return nil
+	}
+	info, err := ParseLink(channel)
+	if err != nil {
+		a.emit("media:error", err.Error())
+		return nil
+	}
+	items, err := b.client.ChannelMedia(a.ctx, info, "", filter, offsetID, limit)
+	if err != nil {
+		a.emit("media:error", err.Error())
+		return nil
+	}
+	return items
+}
+
+// SearchChannel finds messages in a channel by text query ("episode 05
+// 1080p"), matching filenames and captions server-side. Results carry the
+// message ID, so a download can be queued straight from a hit via
+// QueueDownload with a t.me/<channel>/<id> link or StartChannelDownload
+// bounded to it.
+func (a *App) SearchChannel(channel, query string, limit int) []MediaItem {
+	b := a.active()
+	if b == nil {
+		return nil
+	}
+	info, err := ParseLink(channel)
+	if err != nil {
+		a.emit("media:error", err.Error())
+		return nil
+	}
+	items, err := b.client.ChannelMedia(a.ctx, info, query, "", 0, limit)
+	if err != nil {
+		a.emit("media:error", err.Error())
+		return nil
+	}
+	return items
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultCatalog is the built-in English message catalog. Keys are the
+// stable Response codes plus a few task-status keys; values may contain
+// {param} placeholders filled in by FormatMessage. Frontends and the CLI
+// render text from the code and this catalog instead of the raw Message
+// field, so a translated catalog translates the whole surface at once.
+var defaultCatalog = map[string]string{
+	CodeOK:               "done",
+	CodeCodeSent:         "a login code was sent to {phone}",
+	CodePending:          "waiting for confirmation",
+	CodeNotConnected:     "no account is connected",
+	CodeNotAuthenticated: "the session is no longer valid; sign in again",
+	CodeFloodWait:        "Telegram asks to wait {seconds} seconds",
+	CodeLinkInvalid:      "this link cannot be downloaded from",
+	CodeNotFound:         "not found",
+	CodeConflict:         "the operation conflicts with the current state",
+	CodeInvalidArgument:  "invalid input",
+	CodeDiskFull:         "not enough space on the destination disk",
+	CodeInternal:         "something went wrong: {error}",
+
+	"status.scheduled": "scheduled for {time}",
+	"status.queued":    "waiting in the queue",
+	"status.running":   "downloading",
+	"status.paused":    "paused",
+	"status.done":      "finished",
+	"status.failed":    "failed: {error}",
+	"status.cancelled": "cancelled",
+	"status.expired":   "the source message is gone",
+}
+
+// catalogPath is where a language's overlay catalog lives under the state
+// directory: a flat JSON object of key to template.
+func catalogPath(baseDir, lang string) string {
+	return filepath.Join(baseDir, "messages", lang+".json")
+}
+
+// SetLanguage loads the message catalog for a language code (e.g. "de")
+// from messages/<lang>.json under the state directory and overlays it on
+// the built-in English catalog, so partial translations fall back per key
+// instead of per language. "en" or "" restores the built-in catalog.
+func (a *App) SetLanguage(lang string) Response {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if lang == "" || lang == "en" {
+		a.catalog = nil
+		return respOK()
+	}
+	data, err := os.ReadFile(catalogPath(a.baseDir, lang))
+	if os.IsNotExist(err) {
+		return respErr(CodeNotFound, "no catalog for language "+lang)
+	}
+	if err != nil {
+		return respError(err)
+	}
+	overlay := make(map[string]string)
+	if err := json.Unmarshal(data, &overlay); err != nil {
+		return respError(fmt.Errorf("catalog %s: %w", lang, err))
+	}
+	a.catalog = overlay
+	return respOK()
+}
+
+// GetMessageCatalog returns the merged catalog for the current language,
+// built-in English filled in under any keys the overlay misses. The
+// frontend fetches this once and formats locally.
+func (a *App) GetMessageCatalog() map[string]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	merged := make(map[string]string, len(defaultCatalog))
+	for k, v := range defaultCatalog {
+		merged[k] = v
+	}
+	for k, v := range a.catalog {
+		merged[k] = v
+	}
+	return merged
+}
+
+// FormatMessage renders one catalog entry with its {param} placeholders
+// filled in, for the CLI and notifications. An unknown key comes back
+// as-is, so missing entries degrade to the raw code instead of hiding it.
+func (a *App) FormatMessage(key string, params map[string]string) string {
+	a.mu.Lock()
+	tpl, ok := a.catalog[key]
+	a.mu.Unlock()
+	if !ok {
+		if tpl, ok = defaultCatalog[key]; !ok {
+			return key
+		}
+	}
+	for name, value := range params {
+		tpl = strings.ReplaceAll(tpl, "{"+name+"}", value)
+	}
+	return tpl
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// currentSchemaVersion is the config layout this build writes. Bump it and
+// append to migrations whenever the on-disk settings, rules or history
+// format changes shape.
+const currentSchemaVersion = 3
+
+// schemaState is persisted as schema.json in the config dir so upgrades
+// know where to migrate from.
+type schemaState struct {
+	Version int `json:"version"`
+}
+
+// migrations upgrade the config dir one version step at a time; index i
+// migrates from version i to i+1. They must be safe to run on a dir where
+// the old artifact never existed (fresh installs jump straight to current).
+var migrations = []func(dir string) error{
+	migrateSessionIntoAccounts,
+	migrateWatchStateIntoAccounts,
+	migrateQueueToTasks,
+}
+
+// migrateConfig brings the config dir up to the current schema version,
+// backing up the pre-migration state first. It never deletes user data: a
+// failed step leaves the version where it was so the next start retries.
+func migrateConfig() {
+	dir, err := configDir()
+	if err != nil {
+		return
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		// Fresh install: nothing to migrate, just stamp the version.
+		if err := os.MkdirAll(dir, 0o755); err == nil {
+			writeSchemaVersion(dir, currentSchemaVersion)
+		}
+		return
+	}
+
+	version := readSchemaVersion(dir)
+	if version >= currentSchemaVersion {
+		return
+	}
+	if err := backupConfig(dir, version); err != nil {
+		logWarnf("config: backup before migration failed: %v", err)
+		return
+	}
+	for version < currentSchemaVersion {
+		if err := migrations[version](dir); err != nil {
+			logErrorf("config: migration %d -> %d failed: %v", version, version+1, err)
+			return
+		}
+		version++
+		writeSchemaVersion(dir, version)
+		logf("config: migrated to schema version %d", version)
+	}
+}
+
+func readSchemaVersion(dir string) int {
+	data, err := os.ReadFile(filepath.Join(dir, "schema.json"))
+	if err != nil {
+		return 0
+	}
+	var s schemaState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return 0
+	}
+	return s.Version
+}
+
+func writeSchemaVersion(dir string, version int) {
+	data, _ := json.MarshalIndent(schemaState{Version: version}, "", "  ")
+	if err := os.WriteFile(filepath.Join(dir, "schema.json"), data, 0o644); err != nil {
+		logErrorf("config: save schema version: %v", err)
+	}
+}
+
+// backupConfig copies the config dir into backups/v<from>-<timestamp>/ so
+// a bad migration can be undone by hand. Backups and crash reports are not
+// copied into themselves.
+func backupConfig(dir string, from int) error {
+	stamp := time.Now().Format("20060102-150405")
+	dest := filepath.Join(dir, "backups", fmt.Sprintf("v%d-%s", from, stamp))
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if top := firstPathElement(rel); top == "backups" || top == "reports" {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		target := filepath.Join(dest, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		return copyFile(path, target)
+	})
+}
+
+func firstPathElement(rel string) string {
+	for i := 0; i < len(rel); i++ {
+		if os.IsPathSeparator(rel[i]) {
+			return rel[:i]
+		}
+	}
+	return rel
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// migrateSessionIntoAccounts (v0 -> v1) moves the single pre-multi-account
+// session.json into accounts/default/, where AccountManager looks for it.
+func migrateSessionIntoAccounts(dir string) error {
+	old := filepath.Join(dir, "session.json")
+	if _, err := os.Stat(old); os.IsNotExist(err) {
+		return nil
+	}
+	accountDir := filepath.Join(dir, "accounts", "default")
+	if err := os.MkdirAll(accountDir, 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(old, filepath.Join(accountDir, "session.json")); err != nil {
+		return err
+	}
+	// Besides the whole-dir migration backup, take a restorable session
+	// snapshot of the freshly moved login; see sessionbackup.go.
+	if _, err := backupSessionDir(dir, "default"); err != nil {
+		logWarnf("config: session backup after migration failed: %v", err)
+	}
+	return nil
+}
+
+// migrateWatchStateIntoAccounts (v1 -> v2) moves the global watch-state.json
+// into the default account dir; watch state became per-account with
+// multi-account support.
+func migrateWatchStateIntoAccounts(dir string) error {
+	old := filepath.Join(dir, "watch-state.json")
+	if _, err := os.Stat(old); os.IsNotExist(err) {
+		return nil
+	}
+	accountDir := filepath.Join(dir, "accounts", "default")
+	if err := os.MkdirAll(accountDir, 0o755); err != nil {
+		return err
+	}
+	return os.Rename(old, filepath.Join(accountDir, "watch-state.json"))
+}
+
+// migrateQueueToTasks (v2 -> v3) renames the old queue.json to tasks.json;
+// the format is compatible, only the name changed when persistence moved
+// to the task store.
+func migrateQueueToTasks(dir string) error {
+	old := filepath.Join(dir, "queue.json")
+	if _, err := os.Stat(old); os.IsNotExist(err) {
+		return nil
+	}
+	current := filepath.Join(dir, "tasks.json")
+	if _, err := os.Stat(current); err == nil {
+		return nil // already written by a newer build; keep it
+	}
+	return os.Rename(old, current)
+}
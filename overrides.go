@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// overrideSet holds settings supplied outside the UI, for headless and
+// containerized use. Precedence, highest first: command-line flag, then
+// TELETURBO_* environment variable, then whatever the config file or the
+// UI bindings set.
+type overrideSet struct {
+	ConfigDir       string // -config-dir  / TELETURBO_CONFIG_DIR
+	DestDir         string // -dest-dir    / TELETURBO_DEST_DIR
+	ProxyLink       string // -proxy       / TELETURBO_PROXY (tg://proxy share link)
+	GlobalLimit     int64  // -speed-limit / TELETURBO_SPEED_LIMIT (bytes/sec)
+	BackgroundLimit int64  // -bg-speed-limit / TELETURBO_BG_SPEED_LIMIT
+	AppID           int    // -app-id      / TELETURBO_APP_ID
+	AppHash         string // -app-hash    / TELETURBO_APP_HASH
+	Listen          string // -listen      / TELETURBO_LISTEN (headless build only)
+	APIToken        string // -api-token   / TELETURBO_API_TOKEN (headless build only)
+	StateBackend    string // -state-backend / TELETURBO_STATE_BACKEND (json, bolt, sqlite)
+}
+
+var overrides overrideSet
+
+// loadOverrides parses flags with environment variables as their defaults,
+// which gives flags precedence over the environment for free.
+func loadOverrides() {
+	flag.StringVar(&overrides.ConfigDir, "config-dir", os.Getenv("TELETURBO_CONFIG_DIR"),
+		"config and session directory (default: the OS user config dir)")
+	flag.StringVar(&overrides.DestDir, "dest-dir", os.Getenv("TELETURBO_DEST_DIR"),
+		"default download destination for queue calls that pass none")
+	flag.StringVar(&overrides.ProxyLink, "proxy", os.Getenv("TELETURBO_PROXY"),
+		"proxy share link (tg://proxy, tg://socks or t.me/proxy)")
+	flag.Int64Var(&overrides.GlobalLimit, "speed-limit", envInt64("TELETURBO_SPEED_LIMIT"),
+		"global download speed cap in bytes per second, 0 for none")
+	flag.Int64Var(&overrides.BackgroundLimit, "bg-speed-limit", envInt64("TELETURBO_BG_SPEED_LIMIT"),
+		"speed cap for unfocused tasks in bytes per second, 0 for none")
+	flag.IntVar(&overrides.AppID, "app-id", int(envInt64("TELETURBO_APP_ID")),
+		"Telegram API app ID used when AddAccount gets none")
+	flag.StringVar(&overrides.AppHash, "app-hash", os.Getenv("TELETURBO_APP_HASH"),
+		"Telegram API app hash used when AddAccount gets none")
+	listen := os.Getenv("TELETURBO_LISTEN")
+	if listen == "" {
+		listen = ":8722"
+	}
+	flag.StringVar(&overrides.Listen, "listen", listen,
+		"address the headless build serves its REST API on")
+	flag.StringVar(&overrides.APIToken, "api-token", os.Getenv("TELETURBO_API_TOKEN"),
+		"token REST clients must present; empty leaves the API open (bind to localhost!)")
+	backend := os.Getenv("TELETURBO_STATE_BACKEND")
+	if backend == "" {
+		backend = backendJSON
+	}
+	flag.StringVar(&overrides.StateBackend, "state-backend", backend,
+		"state storage backend: json, bolt or sqlite")
+	flag.Parse()
+}
+
+func envInt64(name string) int64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		logWarnf("overrides: %s=%q is not a number, ignoring", name, v)
+		return 0
+	}
+	return n
+}
+
+// configDir returns the TeleTurbo config directory, honoring the
+// -config-dir / TELETURBO_CONFIG_DIR override.
+func configDir() (string, error) {
+	if overrides.ConfigDir != "" {
+		return overrides.ConfigDir, nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("user config dir: %w", err)
+	}
+	return filepath.Join(base, "TeleTurbo"), nil
+}
+
+// applyOverrides pushes override values into a freshly built App. UI
+// bindings can still change them afterwards within the running session.
+func (a *App) applyOverrides() {
+	if overrides.GlobalLimit > 0 {
+		a.globalLimit.SetRate(overrides.GlobalLimit)
+	}
+	if overrides.BackgroundLimit > 0 {
+		a.bgLimit.SetRate(overrides.BackgroundLimit)
+	}
+	if overrides.ProxyLink != "" {
+		cfg, err := ParseProxyLink(overrides.ProxyLink)
+		if err != nil {
+			logWarnf("overrides: proxy link: %v", err)
+		} else {
+			a.proxy = cfg
+		}
+	}
+}
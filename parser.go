@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// LinkInfo is the parsed form of a Telegram message link.
+type LinkInfo struct {
+	Username   string `json:"username"`
+	ChannelID  int64  `json:"channelId"`
+	MessageID  int    `json:"messageId"`
+	TopicID    int    `json:"topicId"` // forum topic, 0 outside forums
+	Private    bool   `json:"private"`
+	InviteHash string `json:"inviteHash"` // set for invite links, which carry no message
+
+	// Peer marks non-channel sources: "self" for Saved Messages, "user"
+	// for a DM, "chat" for a basic group. Empty means a channel, which
+	// every field above describes. Non-channel messages are fetched by
+	// bare ID from the account's own message space.
+	Peer   string `json:"peer,omitempty"`
+	PeerID int64  `json:"peerId,omitempty"`
+}
+
+// ParseLink parses the t.me link formats TeleTurbo supports:
+//
+//	https://t.me/c/1234567890/123      private channel
+//	https://t.me/channelname/123       public channel
+//	https://t.me/c/1234567890/55/123   private forum topic
+//	https://t.me/channelname/55/123    public forum topic
+//	t.me/c/1234567890/123              short form
+//	https://t.me/+AbCdEf               invite link
+//	https://t.me/joinchat/AbCdEf       legacy invite link
+//	saved/123                          Saved Messages (TeleTurbo shorthand)
+//
+// In the forum forms the middle segment is the topic ID, not the message.
+// Invite links carry no message ID; they only set InviteHash and must be
+// joined before anything can be downloaded. Saved Messages have no public
+// URL, so TeleTurbo accepts its own "saved/<message ID>" shorthand.
+func ParseLink(raw string) (*LinkInfo, error) {
+	s := strings.TrimSpace(raw)
+	if strings.HasPrefix(s, "tg://") {
+		return parseDeepLink(s)
+	}
+	if rest := strings.TrimPrefix(s, "saved/"); rest != s {
+		msgID, err := strconv.Atoi(rest)
+		if err != nil || msgID == 0 {
+			return nil, fmt.Errorf("bad message ID in %q", raw)
+		}
+		return &LinkInfo{Peer: "self", MessageID: msgID, Private: true}, nil
+	}
+	s = strings.TrimPrefix(s, "https://")
+	s = strings.TrimPrefix(s, "http://")
+	if i := strings.IndexByte(s, '?'); i >= 0 {
+		s = s[:i]
+	}
+	if !strings.HasPrefix(s, "t.me/") {
+		return nil, fmt.Errorf("not a t.me link: %q", raw)
+	}
+	parts := strings.Split(strings.Trim(s[len("t.me/"):], "/"), "/")
+	if len(parts) >= 1 {
+		if hash := strings.TrimPrefix(parts[0], "+"); hash != parts[0] && hash != "" {
+			return &LinkInfo{InviteHash: hash, Private: true}, nil
+		}
+		if parts[0] == "joinchat" && len(parts) >= 2 && parts[1] != "" {
+			return &LinkInfo{InviteHash: parts[1], Private: true}, nil
+		}
+	}
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("link has no message ID: %q", raw)
+	}
+
+	if parts[0] == "c" {
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("private link has no message ID: %q", raw)
+		}
+		channelID, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad channel ID in %q: %w", raw, err)
+		}
+		topicID, msgID, err := parseTopicAndMessage(parts[2:], raw)
+		if err != nil {
+			return nil, err
+		}
+		return &LinkInfo{ChannelID: channelID, TopicID: topicID, MessageID: msgID, Private: true}, nil
+	}
+
+	topicID, msgID, err := parseTopicAndMessage(parts[1:], raw)
+	if err != nil {
+		return nil, err
+	}
+	return &LinkInfo{Username: parts[0], TopicID: topicID, MessageID: msgID}, nil
+}
+
+// parseDeepLink handles the tg:// forms produced by Telegram Desktop's
+// "Copy Link" in various contexts:
+//
+//	tg://resolve?domain=name&post=123[&thread=55]
+//	tg://privatepost?channel=1234567890&msg_id=123[&thread=55]
+//	tg://openmessage?chat_id=1234567890&message_id=123   basic group
+//	tg://openmessage?user_id=1234567890&message_id=123   DM
+//	tg://join?invite=HASH
+func parseDeepLink(raw string) (*LinkInfo, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("bad tg:// link %q: %w", raw, err)
+	}
+	kind := u.Host
+	if kind == "" {
+		kind = u.Opaque
+	}
+	q := u.Query()
+	num := func(key string) int {
+		n, _ := strconv.Atoi(q.Get(key))
+		return n
+	}
+	switch kind {
+	case "resolve":
+		domain := q.Get("domain")
+		post := num("post")
+		if domain == "" || post == 0 {
+			return nil, fmt.Errorf("tg://resolve link needs domain and post: %q", raw)
+		}
+		return &LinkInfo{Username: domain, TopicID: num("thread"), MessageID: post}, nil
+	case "privatepost":
+		channelID, _ := strconv.ParseInt(q.Get("channel"), 10, 64)
+		msgID := num("msg_id")
+		if channelID == 0 || msgID == 0 {
+			return nil, fmt.Errorf("tg://privatepost link needs channel and msg_id: %q", raw)
+		}
+		return &LinkInfo{ChannelID: channelID, TopicID: num("thread"), MessageID: msgID, Private: true}, nil
+	case "join":
+		hash := q.Get("invite")
+		if hash == "" {
+			return nil, fmt.Errorf("tg://join link needs invite: %q", raw)
+		}
+		return &LinkInfo{InviteHash: hash, Private: true}, nil
+	case "openmessage":
+		msgID := num("message_id")
+		if msgID == 0 {
+			return nil, fmt.Errorf("tg://openmessage link needs message_id: %q", raw)
+		}
+		if userID, _ := strconv.ParseInt(q.Get("user_id"), 10, 64); userID != 0 {
+			return &LinkInfo{Peer: "user", PeerID: userID, MessageID: msgID, Private: true}, nil
+		}
+		chatID, _ := strconv.ParseInt(q.Get("chat_id"), 10, 64)
+		if chatID == 0 {
+			return nil, fmt.Errorf("tg://openmessage link needs chat_id or user_id: %q", raw)
+		}
+		return &LinkInfo{Peer: "chat", PeerID: chatID, MessageID: msgID, Private: true}, nil
+	default:
+		return nil, fmt.Errorf("unsupported tg:// link kind %q", kind)
+	}
+}
+
+// parseTopicAndMessage decodes the trailing numeric segments of a link:
+// either [message] or [topic, message] for forum links.
+func parseTopicAndMessage(parts []string, raw string) (topicID, msgID int, err error) {
+	switch len(parts) {
+	case 1:
+		msgID, err = strconv.Atoi(parts[0])
+		if err != nil {
+			return 0, 0, fmt.Errorf("bad message ID in %q: %w", raw, err)
+		}
+		return 0, msgID, nil
+	case 2:
+		topicID, err = strconv.Atoi(parts[0])
+		if err != nil {
+			return 0, 0, fmt.Errorf("bad topic ID in %q: %w", raw, err)
+		}
+		msgID, err = strconv.Atoi(parts[1])
+		if err != nil {
+			return 0, 0, fmt.Errorf("bad message ID in %q: %w", raw, err)
+		}
+		return topicID, msgID, nil
+	default:
+		return 0, 0, fmt.Errorf("too many path segments in %q", raw)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import "testing"
+
+func TestParseLink(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  string
+		want LinkInfo
+	}{
+		{
+			name: "public channel",
+			raw:  "https://t.me/channelname/123",
+			want: LinkInfo{Username: "channelname", MessageID: 123},
+		},
+		{
+			name: "private channel",
+			raw:  "https://t.me/c/1234567890/123",
+			want: LinkInfo{ChannelID: 1234567890, MessageID: 123, Private: true},
+		},
+		{
+			name: "public forum topic",
+			raw:  "https://t.me/channelname/55/123",
+			want: LinkInfo{Username: "channelname", TopicID: 55, MessageID: 123},
+		},
+		{
+			name: "private forum topic",
+			raw:  "https://t.me/c/1234567890/55/123",
+			want: LinkInfo{ChannelID: 1234567890, TopicID: 55, MessageID: 123, Private: true},
+		},
+		{
+			name: "short form without scheme",
+			raw:  "t.me/c/1234567890/123",
+			want: LinkInfo{ChannelID: 1234567890, MessageID: 123, Private: true},
+		},
+		{
+			name: "query string stripped",
+			raw:  "https://t.me/channelname/123?single",
+			want: LinkInfo{Username: "channelname", MessageID: 123},
+		},
+		{
+			name: "surrounding whitespace",
+			raw:  "  https://t.me/channelname/123\n",
+			want: LinkInfo{Username: "channelname", MessageID: 123},
+		},
+		{
+			name: "invite link",
+			raw:  "https://t.me/+AbCdEf",
+			want: LinkInfo{InviteHash: "AbCdEf", Private: true},
+		},
+		{
+			name: "legacy invite link",
+			raw:  "https://t.me/joinchat/AbCdEf",
+			want: LinkInfo{InviteHash: "AbCdEf", Private: true},
+		},
+		{
+			name: "saved messages shorthand",
+			raw:  "saved/123",
+			want: LinkInfo{Peer: "self", MessageID: 123, Private: true},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseLink(tc.raw)
+			if err != nil {
+				t.Fatalf("ParseLink(%q): %v", tc.raw, err)
+			}
+			if *got != tc.want {
+				t.Errorf("ParseLink(%q) = %+v, want %+v", tc.raw, *got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseLinkErrors(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"https://example.com/c/123/456",
+		"https://t.me/channelname",
+		"https://t.me/c/1234567890",
+		"https://t.me/c/notanumber/123",
+		"https://t.me/channelname/notanumber",
+		"https://t.me/channelname/1/2/3",
+		"saved/notanumber",
+		"saved/0",
+	} {
+		if got, err := ParseLink(raw); err == nil {
+			t.Errorf("ParseLink(%q) = %+v, want error", raw, got)
+		}
+	}
+}
+
+func TestParseDeepLink(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  string
+		want LinkInfo
+	}{
+		{
+			name: "resolve",
+			raw:  "tg://resolve?domain=channelname&post=123",
+			want: LinkInfo{Username: "channelname", MessageID: 123},
+		},
+		{
+			name: "resolve with thread",
+			raw:  "tg://resolve?domain=channelname&post=123&thread=55",
+			want: LinkInfo{Username: "channelname", TopicID: 55, MessageID: 123},
+		},
+		{
+			name: "privatepost",
+			raw:  "tg://privatepost?channel=1234567890&msg_id=123",
+			want: LinkInfo{ChannelID: 1234567890, MessageID: 123, Private: true},
+		},
+		{
+			name: "join",
+			raw:  "tg://join?invite=AbCdEf",
+			want: LinkInfo{InviteHash: "AbCdEf", Private: true},
+		},
+		{
+			name: "openmessage user",
+			raw:  "tg://openmessage?user_id=1234567890&message_id=123",
+			want: LinkInfo{Peer: "user", PeerID: 1234567890, MessageID: 123, Private: true},
+		},
+		{
+			name: "openmessage chat",
+			raw:  "tg://openmessage?chat_id=1234567890&message_id=123",
+			want: LinkInfo{Peer: "chat", PeerID: 1234567890, MessageID: 123, Private: true},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseLink(tc.raw)
+			if err != nil {
+				t.Fatalf("ParseLink(%q): %v", tc.raw, err)
+			}
+			if *got != tc.want {
+				t.Errorf("ParseLink(%q) = %+v, want %+v", tc.raw, *got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseDeepLinkErrors(t *testing.T) {
+	for _, raw := range []string{
+		"tg://resolve?domain=channelname",
+		"tg://resolve?post=123",
+		"tg://privatepost?channel=1234567890",
+		"tg://join",
+		"tg://openmessage?message_id=123",
+		"tg://openmessage?chat_id=1234567890",
+		"tg://settings",
+	} {
+		if got, err := ParseLink(raw); err == nil {
+			t.Errorf("ParseLink(%q) = %+v, want error", raw, got)
+		}
+	}
+}
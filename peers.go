@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gotd/td/tg"
+)
+
+// ResolvePeer generalizes ResolveChannel to every peer kind a link can
+// name: InputPeerSelf for Saved Messages, InputPeerUser for DMs,
+// InputPeerChat for basic groups and InputPeerChannel for everything
+// ResolveChannel handles. Users need an access-hash lookup; basic groups
+// have no access hash at all.
+func (c *TGClient) ResolvePeer(ctx context.Context, link *LinkInfo) (tg.InputPeerClass, error) {
+	switch link.Peer {
+	case "self":
+		return &tg.InputPeerSelf{}, nil
+	case "user":
+		hash, err := c.userAccessHash(ctx, link.PeerID)
+		if err != nil {
+			return nil, err
+		}
+		return &tg.InputPeerUser{UserID: link.PeerID, AccessHash: hash}, nil
+	case "chat":
+		return &tg.InputPeerChat{ChatID: link.PeerID}, nil
+	default:
+		ch, err := c.ResolveChannel(ctx, link)
+		if err != nil {
+			return nil, err
+		}
+		return &tg.InputPeerChannel{ChannelID: ch.ChannelID, AccessHash: ch.AccessHash}, nil
+	}
+}
+
+// userAccessHash finds a user's access hash, caching hits like the channel
+// caches. users.getUsers with a bare ID only answers for users this
+// session has already seen, so a miss falls back to scanning the dialog
+// list, which covers anyone the account has a conversation with — the
+// only users whose messages can be downloaded anyway.
+func (c *TGClient) userAccessHash(ctx context.Context, userID int64) (int64, error) {
+	c.mu.Lock()
+	if hash, ok := c.userHashCache[userID]; ok {
+		c.mu.Unlock()
+		return hash, nil
+	}
+	c.mu.Unlock()
+
+	res, err := c.api.UsersGetUsers(ctx, []tg.InputUserClass{&tg.InputUser{UserID: userID}})
+	if err == nil {
+		if hash, ok := c.cacheUserHashes(res, userID); ok {
+			return hash, nil
+		}
+	}
+	dialogs, err := c.api.MessagesGetDialogs(ctx, &tg.MessagesGetDialogsRequest{
+		OffsetPeer: &tg.InputPeerEmpty{},
+		Limit:      dialogsPageSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("resolve user %d: %w", userID, err)
+	}
+	_, _, _, users, ok := splitDialogs(dialogs)
+	if !ok {
+		return 0, fmt.Errorf("resolve user %d: unexpected dialogs type %T", userID, dialogs)
+	}
+	if hash, ok := c.cacheUserHashes(users, userID); ok {
+		return hash, nil
+	}
+	return 0, fmt.Errorf("no access hash for user %d; open a conversation with them first", userID)
+}
+
+// cacheUserHashes stores the access hash of every full user in the list
+// and reports whether wanted was among them.
+func (c *TGClient) cacheUserHashes(users []tg.UserClass, wanted int64) (int64, bool) {
+	var hash int64
+	found := false
+	c.mu.Lock()
+	for _, u := range users {
+		usr, ok := u.(*tg.User)
+		if !ok {
+			continue
+		}
+		c.userHashCache[usr.ID] = usr.AccessHash
+		if usr.ID == wanted {
+			hash, found = usr.AccessHash, true
+		}
+	}
+	c.mu.Unlock()
+	return hash, found
+}
+
+// GetMessageDirect fetches a message by bare ID from the account's own
+// message space, which covers Saved Messages, DMs and basic groups —
+// everything outside channels, where IDs are per-account rather than
+// per-channel.
+func (c *TGClient) GetMessageDirect(ctx context.Context, msgID int) (*tg.Message, error) {
+	res, err := c.api.MessagesGetMessages(ctx, []tg.InputMessageClass{&tg.InputMessageID{ID: msgID}})
+	if err != nil {
+		return nil, fmt.Errorf("get messages: %w", err)
+	}
+	var found []tg.MessageClass
+	switch m := res.(type) {
+	case *tg.MessagesMessages:
+		found = m.Messages
+	case *tg.MessagesMessagesSlice:
+		found = m.Messages
+	default:
+		return nil, fmt.Errorf("unexpected messages type %T", res)
+	}
+	for _, m := range found {
+		if msg, ok := m.(*tg.Message); ok && msg.ID == msgID {
+			return msg, nil
+		}
+	}
+	return nil, &messageGoneError{id: msgID}
+}
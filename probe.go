@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+
+	"github.com/gotd/td/tg"
+)
+
+// MediaVariant describes one downloadable version of a message's media.
+// Index 0 is the primary document; higher indexes are alt versions such as
+// lower video qualities.
+type MediaVariant struct {
+	Index    int    `json:"index"`
+	FileName string `json:"fileName"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+// ProbeResult is the metadata of a link's media without downloading it.
+type ProbeResult struct {
+	Link       *LinkInfo      `json:"link"`
+	FileName   string         `json:"fileName"`
+	Size       int64          `json:"size"`
+	TTLSeconds int            `json:"ttlSeconds"`
+	Variants   []MediaVariant `json:"variants"`
+}
+
+// Probe fetches a link's message and reports its media metadata, including
+// all available quality variants, so the user can pick one before
+// downloading.
+func (d *Downloader) Probe(ctx context.Context, link *LinkInfo) (*ProbeResult, error) {
+	ch, err := d.client.ResolveChannel(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := d.client.GetMessage(ctx, ch, link.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	return probeMessage(link, msg)
+}
+
+// probeBatchLimit caps how many IDs one channels.getMessages call carries.
+const probeBatchLimit = 100
+
+// ProbeMany fetches metadata for many message IDs of the same channel in
+// as few API round trips as possible (one per 100 IDs), for the gallery
+// and range-planning views. Messages without downloadable media are
+// silently absent from the result.
+func (d *Downloader) ProbeMany(ctx context.Context, link *LinkInfo, ids []int) ([]*ProbeResult, error) {
+	ch, err := d.client.ResolveChannel(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	var results []*ProbeResult
+	for start := 0; start < len(ids); start += probeBatchLimit {
+		end := start + probeBatchLimit
+		if end > len(ids) {
+			end = len(ids)
+		}
+		msgs, err := d.client.GetMessages(ctx, ch, ids[start:end])
+		if err != nil {
+			return results, err
+		}
+		for _, msg := range msgs {
+			msgLink := &LinkInfo{
+				Username:  link.Username,
+				ChannelID: link.ChannelID,
+				MessageID: msg.ID,
+				Private:   link.Private,
+			}
+			if result, err := probeMessage(msgLink, msg); err == nil {
+				results = append(results, result)
+			}
+		}
+	}
+	return results, nil
+}
+
+// probeMessage builds the probe result for one fetched message.
+func probeMessage(link *LinkInfo, msg *tg.Message) (*ProbeResult, error) {
+	_, size, name, err := mediaLocation(msg)
+	if err != nil {
+		return nil, err
+	}
+	result := &ProbeResult{
+		Link:       link,
+		FileName:   name,
+		Size:       size,
+		TTLSeconds: mediaTTL(msg),
+	}
+	for i, doc := range variantDocuments(msg) {
+		v := MediaVariant{
+			Index:    i,
+			FileName: documentFileName(doc),
+			Size:     doc.Size,
+			MimeType: doc.MimeType,
+		}
+		for _, attr := range doc.Attributes {
+			if video, ok := attr.(*tg.DocumentAttributeVideo); ok {
+				v.Width, v.Height = video.W, video.H
+			}
+		}
+		result.Variants = append(result.Variants, v)
+	}
+	return result, nil
+}
+
+// videoHeight extracts a document's video height, or 0 when unknown.
+func videoHeight(doc *tg.Document) int {
+	for _, attr := range doc.Attributes {
+		if video, ok := attr.(*tg.DocumentAttributeVideo); ok {
+			return video.H
+		}
+	}
+	return 0
+}
+
+// pickVariantIndex applies a quality preference ("highest", "lowest" or
+// "720p" for closest-to-720p) to the available variants. Ties and unknown
+// preferences resolve to the primary document.
+func pickVariantIndex(docs []*tg.Document, pref string) int {
+	if len(docs) < 2 {
+		return 0
+	}
+	best := 0
+	switch pref {
+	case "highest":
+		for i, doc := range docs {
+			if videoHeight(doc) > videoHeight(docs[best]) {
+				best = i
+			}
+		}
+	case "lowest":
+		for i, doc := range docs {
+			if h := videoHeight(doc); h > 0 && (videoHeight(docs[best]) == 0 || h < videoHeight(docs[best])) {
+				best = i
+			}
+		}
+	case "720p":
+		distance := func(doc *tg.Document) int {
+			h := videoHeight(doc)
+			if h == 0 {
+				return 1 << 30
+			}
+			if h > 720 {
+				return h - 720
+			}
+			return 720 - h
+		}
+		for i, doc := range docs {
+			if distance(doc) < distance(docs[best]) {
+				best = i
+			}
+		}
+	}
+	return best
+}
+
+// variantDocuments returns the message's document plus any alt versions.
+// Non-document media yields nil: there is nothing to choose between.
+func variantDocuments(msg *tg.Message) []*tg.Document {
+	media, ok := msg.GetMedia()
+	if !ok {
+		return nil
+	}
+	m, ok := media.(*tg.MessageMediaDocument)
+	if !ok {
+		return nil
+	}
+	doc, ok := m.Document.AsNotEmpty()
+	if !ok {
+		return nil
+	}
+	docs := []*tg.Document{doc}
+	for _, alt := range m.AltDocuments {
+		if altDoc, ok := alt.AsNotEmpty(); ok {
+			docs = append(docs, altDoc)
+		}
+	}
+	return docs
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+// videoDoc builds a document carrying a video attribute of the given
+// height; height 0 yields a plain document with no video attribute.
+func videoDoc(height int) *tg.Document {
+	doc := &tg.Document{}
+	if height > 0 {
+		doc.Attributes = []tg.DocumentAttributeClass{
+			&tg.DocumentAttributeVideo{H: height},
+		}
+	}
+	return doc
+}
+
+func TestPickVariantIndex(t *testing.T) {
+	variants := []*tg.Document{videoDoc(720), videoDoc(1080), videoDoc(360), videoDoc(0)}
+	cases := []struct {
+		name string
+		docs []*tg.Document
+		pref string
+		want int
+	}{
+		{name: "highest", docs: variants, pref: "highest", want: 1},
+		{name: "lowest skips heightless", docs: variants, pref: "lowest", want: 2},
+		{name: "720p exact", docs: variants, pref: "720p", want: 0},
+		{name: "720p closest", docs: []*tg.Document{videoDoc(1080), videoDoc(480)}, pref: "720p", want: 1},
+		{name: "unknown preference keeps primary", docs: variants, pref: "best", want: 0},
+		{name: "empty preference keeps primary", docs: variants, pref: "", want: 0},
+		{name: "single variant", docs: variants[:1], pref: "highest", want: 0},
+		{name: "no variants", docs: nil, pref: "highest", want: 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := pickVariantIndex(tc.docs, tc.pref); got != tc.want {
+				t.Errorf("pickVariantIndex(%s) = %d, want %d", tc.pref, got, tc.want)
+			}
+		})
+	}
+}
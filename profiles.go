@@ -0,0 +1,68 @@
+//go:build headless
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Profile is one isolated user of a shared headless instance: its own
+// sessions, queue, history and destination, selected by API token. The
+// list lives in profiles.json in the config dir; without it the server
+// runs in classic single-user mode.
+type Profile struct {
+	Name    string `json:"name"`
+	Token   string `json:"token"`
+	DestDir string `json:"destDir"`
+}
+
+// loadProfiles reads profiles.json; a missing file means single-user mode.
+func loadProfiles() ([]Profile, error) {
+	base, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(base, "profiles.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var profiles []Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("corrupt profiles.json: %w", err)
+	}
+	seen := make(map[string]bool, len(profiles))
+	for _, p := range profiles {
+		if p.Name == "" || p.Token == "" {
+			return nil, fmt.Errorf("profile needs both name and token")
+		}
+		if seen[p.Token] {
+			return nil, fmt.Errorf("profile %q reuses another profile's token", p.Name)
+		}
+		seen[p.Token] = true
+	}
+	return profiles, nil
+}
+
+// buildProfileApps creates one isolated App per profile, each with its
+// own state directory under profiles/<name>.
+func buildProfileApps(profiles []Profile) (map[string]*App, error) {
+	base, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	apps := make(map[string]*App, len(profiles))
+	for _, p := range profiles {
+		app := NewApp(filepath.Join(base, "profiles", p.Name))
+		if p.DestDir != "" {
+			app.destDir = p.DestDir
+		}
+		apps[p.Token] = app
+	}
+	return apps, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProgressPolicy controls how often progress events reach one delivery
+// channel: an event passes when the interval has elapsed since the last
+// one for the task, or when progress advanced by the given percentage.
+type ProgressPolicy struct {
+	IntervalMs int     `json:"intervalMs"`
+	MinPercent float64 `json:"minPercent"`
+}
+
+// progressChannels are the delivery channels with their own throttling:
+// the desktop UI, webhook pushes and remote RPC consumers.
+var progressChannels = map[string]bool{"ui": true, "webhook": true, "rpc": true}
+
+// progressGate coalesces per-task progress events per delivery channel, so
+// a fast download doesn't flood low-power machines or remote consumers.
+type progressGate struct {
+	mu       sync.Mutex
+	policies map[string]ProgressPolicy
+	marks    map[string]progressMark // channel + task ID
+}
+
+type progressMark struct {
+	at      time.Time
+	percent float64
+}
+
+func newProgressGate() *progressGate {
+	return &progressGate{
+		policies: map[string]ProgressPolicy{
+			"ui":      {IntervalMs: 250, MinPercent: 1},
+			"webhook": {IntervalMs: 2000, MinPercent: 5},
+			"rpc":     {IntervalMs: 1000, MinPercent: 1},
+		},
+		marks: make(map[string]progressMark),
+	}
+}
+
+// SetPolicy replaces one channel's throttling policy.
+func (g *progressGate) SetPolicy(channel string, p ProgressPolicy) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.policies[channel] = p
+}
+
+// Allow reports whether a progress event for the task should be delivered
+// on the channel now, and records it if so.
+func (g *progressGate) Allow(channel string, task *DownloadTask) bool {
+	var percent float64
+	if _, size := task.fileMeta(); size > 0 {
+		percent = float64(atomic.LoadInt64(&task.Written)) / float64(size) * 100
+	}
+	now := time.Now()
+	key := channel + "\x00" + task.ID
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	policy := g.policies[channel]
+	mark, seen := g.marks[key]
+	if seen {
+		interval := time.Duration(policy.IntervalMs) * time.Millisecond
+		if now.Sub(mark.at) < interval && percent-mark.percent < policy.MinPercent {
+			return false
+		}
+	}
+	if percent >= 100 {
+		delete(g.marks, key)
+	} else {
+		g.marks[key] = progressMark{at: now, percent: percent}
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// ProxyConfig routes the Telegram connection through a SOCKS5, HTTP or
+// MTProto proxy. An empty Type means a direct connection.
+type ProxyConfig struct {
+	Type     string `json:"type"` // "socks5", "http" or "mtproto"
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Secret is the hex-encoded MTProxy secret; only used for "mtproto".
+	Secret string `json:"secret"`
+}
+
+// mtproxySecret decodes the configured secret, accepting hex (including
+// the ee/dd-prefixed forms) and base64url as found in share links.
+func (p *ProxyConfig) mtproxySecret() ([]byte, error) {
+	if s, err := hex.DecodeString(p.Secret); err == nil {
+		return s, nil
+	}
+	if s, err := base64.RawURLEncoding.DecodeString(p.Secret); err == nil {
+		return s, nil
+	}
+	return nil, fmt.Errorf("secret is neither hex nor base64url")
+}
+
+func (p *ProxyConfig) addr() string {
+	return net.JoinHostPort(p.Host, fmt.Sprint(p.Port))
+}
+
+// validate checks the config is usable without opening a connection.
+func (p *ProxyConfig) validate() error {
+	if p.Type == "mtproto" {
+		_, err := p.mtproxySecret()
+		return err
+	}
+	_, err := p.dialFunc()
+	return err
+}
+
+// dialFunc builds the dialer gotd uses to reach Telegram's data centers.
+func (p *ProxyConfig) dialFunc() (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	switch p.Type {
+	case "socks5":
+		var auth *proxy.Auth
+		if p.Username != "" {
+			auth = &proxy.Auth{User: p.Username, Password: p.Password}
+		}
+		d, err := proxy.SOCKS5("tcp", p.addr(), auth, proxy.Direct)
+		if err != nil {
+			return nil, err
+		}
+		cd, ok := d.(proxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("socks5 dialer has no context support")
+		}
+		return cd.DialContext, nil
+	case "http":
+		return p.dialHTTPConnect, nil
+	default:
+		return nil, fmt.Errorf("unknown proxy type %q", p.Type)
+	}
+}
+
+// dialHTTPConnect tunnels a TCP connection through an HTTP CONNECT proxy.
+func (p *ProxyConfig) dialHTTPConnect(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.addr())
+	if err != nil {
+		return nil, err
+	}
+	req := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr)
+	if p.Username != "" {
+		cred := base64.StdEncoding.EncodeToString([]byte(p.Username + ":" + p.Password))
+		req += "Proxy-Authorization: Basic " + cred + "\r\n"
+	}
+	req += "\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT: %s", resp.Status)
+	}
+	return conn, nil
+}
+
+// testDCAddr is a production Telegram data center used for proxy checks.
+const testDCAddr = "149.154.167.50:443"
+
+// Test verifies the proxy can actually reach Telegram. For MTProto
+// proxies only TCP reachability of the proxy itself is checked, since the
+// handshake needs a full client.
+func (p *ProxyConfig) Test(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if p.Type == "mtproto" {
+		if _, err := p.mtproxySecret(); err != nil {
+			return err
+		}
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", p.addr())
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	dial, err := p.dialFunc()
+	if err != nil {
+		return err
+	}
+	conn, err := dial(ctx, "tcp", testDCAddr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// ParseProxyLink imports proxy settings from the share-link formats
+// tg://proxy?server=..&port=..&secret=.. , https://t.me/proxy?... and the
+// SOCKS variant tg://socks?server=..&port=..&user=..&pass=.. .
+func ParseProxyLink(raw string) (*ProxyConfig, error) {
+	s := strings.TrimSpace(raw)
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("bad proxy link %q: %w", raw, err)
+	}
+	var kind string
+	switch {
+	case u.Scheme == "tg" && (u.Host == "proxy" || u.Opaque == "proxy"):
+		kind = "mtproto"
+	case u.Scheme == "tg" && (u.Host == "socks" || u.Opaque == "socks"):
+		kind = "socks5"
+	case (u.Scheme == "https" || u.Scheme == "http" || u.Scheme == "") &&
+		strings.Trim(u.Path, "/") == "proxy" && strings.Contains(u.Host+u.Path, "t.me"):
+		kind = "mtproto"
+	default:
+		return nil, fmt.Errorf("not a proxy link: %q", raw)
+	}
+	q := u.Query()
+	port, err := strconv.Atoi(q.Get("port"))
+	if err != nil {
+		return nil, fmt.Errorf("bad port in %q", raw)
+	}
+	cfg := &ProxyConfig{
+		Type:     kind,
+		Host:     q.Get("server"),
+		Port:     port,
+		Username: q.Get("user"),
+		Password: q.Get("pass"),
+		Secret:   q.Get("secret"),
+	}
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("missing server in %q", raw)
+	}
+	if kind == "mtproto" {
+		if _, err := cfg.mtproxySecret(); err != nil {
+			return nil, fmt.Errorf("bad secret in %q: %w", raw, err)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestParseProxyLink(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  string
+		want ProxyConfig
+	}{
+		{
+			name: "mtproto share link",
+			raw:  "tg://proxy?server=proxy.example.com&port=443&secret=ee000102030405060708090a0b0c0d0e0f",
+			want: ProxyConfig{Type: "mtproto", Host: "proxy.example.com", Port: 443, Secret: "ee000102030405060708090a0b0c0d0e0f"},
+		},
+		{
+			name: "t.me form",
+			raw:  "https://t.me/proxy?server=proxy.example.com&port=443&secret=dd00112233445566778899aabbccddeeff",
+			want: ProxyConfig{Type: "mtproto", Host: "proxy.example.com", Port: 443, Secret: "dd00112233445566778899aabbccddeeff"},
+		},
+		{
+			name: "t.me form over http",
+			raw:  "http://t.me/proxy?server=proxy.example.com&port=443&secret=00112233445566778899aabbccddeeff",
+			want: ProxyConfig{Type: "mtproto", Host: "proxy.example.com", Port: 443, Secret: "00112233445566778899aabbccddeeff"},
+		},
+		{
+			name: "socks with credentials",
+			raw:  "tg://socks?server=proxy.example.com&port=1080&user=alice&pass=secret",
+			want: ProxyConfig{Type: "socks5", Host: "proxy.example.com", Port: 1080, Username: "alice", Password: "secret"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseProxyLink(tc.raw)
+			if err != nil {
+				t.Fatalf("ParseProxyLink(%q): %v", tc.raw, err)
+			}
+			if *got != tc.want {
+				t.Errorf("ParseProxyLink(%q) = %+v, want %+v", tc.raw, *got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseProxyLinkErrors(t *testing.T) {
+	for _, raw := range []string{
+		"https://t.me/channelname/123",           // not a proxy link
+		"tg://proxy?port=443&secret=ee0011",      // missing server
+		"tg://proxy?server=proxy.example.com",    // missing port
+		"tg://socks?server=proxy.example.com",    // missing port
+		"tg://proxy?server=h&port=443&secret=!!", // secret neither hex nor base64url
+	} {
+		if got, err := ParseProxyLink(raw); err == nil {
+			t.Errorf("ParseProxyLink(%q) = %+v, want error", raw, got)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// defaultMaxConcurrent is how many downloads may run at once before
+// further tasks wait in the queue.
+const defaultMaxConcurrent = 3
+
+// Tiny files are dominated by per-file overhead rather than bandwidth, so
+// they run in their own lane with a much higher concurrency cap than the
+// big-file lane.
+const (
+	smallFileThreshold        = 1 << 20 // bytes; below this a task counts as small
+	defaultSmallMaxConcurrent = 12
+)
+
+// queueItem pairs a task with the function that actually runs it (bound to
+// the right account's downloader).
+type queueItem struct {
+	task     *DownloadTask
+	priority int
+	seq      int64
+	run      func(ctx context.Context) error
+	small    bool     // which lane the item was dispatched into
+	deps     []string // task IDs that must finish successfully first
+}
+
+// QueueState is the snapshot handed to the frontend.
+type QueueState struct {
+	MaxConcurrent      int             `json:"maxConcurrent"`
+	SmallMaxConcurrent int             `json:"smallMaxConcurrent"`
+	Queued             []*DownloadTask `json:"queued"`
+	Active             []*DownloadTask `json:"active"`
+	Finished           []*DownloadTask `json:"finished"`
+}
+
+// DownloadQueue runs download jobs with a concurrency cap and per-job
+// priorities. Higher priority runs first; equal priorities keep FIFO order.
+type DownloadQueue struct {
+	mu       sync.Mutex
+	pending  []*queueItem
+	active   map[string]*queueItem
+	finished []*DownloadTask
+	max      int
+	smallMax int
+	seq      int64
+	paused   bool
+	// outcome records the final status of every task that went through the
+	// queue, for dependency checks.
+	outcome map[string]string
+	// emit, when set, reports tasks the queue itself moves to a terminal
+	// state, e.g. dependents failed without ever running.
+	emit func(event string, data interface{})
+}
+
+// SetEmit installs the event sink for queue-side terminal transitions.
+func (q *DownloadQueue) SetEmit(emit func(event string, data interface{})) {
+	q.mu.Lock()
+	q.emit = emit
+	q.mu.Unlock()
+}
+
+// NewDownloadQueue creates a queue with the given concurrency cap.
+func NewDownloadQueue(max int) *DownloadQueue {
+	if max <= 0 {
+		max = defaultMaxConcurrent
+	}
+	return &DownloadQueue{
+		active:   make(map[string]*queueItem),
+		outcome:  make(map[string]string),
+		max:      max,
+		smallMax: defaultSmallMaxConcurrent,
+	}
+}
+
+// Enqueue adds a job; it starts immediately if a slot is free.
+func (q *DownloadQueue) Enqueue(task *DownloadTask, priority int, run func(ctx context.Context) error) {
+	q.EnqueueAfter(task, priority, nil, run)
+}
+
+// EnqueueAfter adds a job that only starts once every task in deps has
+// finished successfully. A failed or cancelled dependency fails the
+// dependent task instead of leaving it stuck.
+func (q *DownloadQueue) EnqueueAfter(task *DownloadTask, priority int, deps []string, run func(ctx context.Context) error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.seq++
+	q.pending = append(q.pending, &queueItem{task: task, priority: priority, seq: q.seq, run: run, deps: deps})
+	q.sortLocked()
+	q.dispatchLocked()
+}
+
+// SetMaxConcurrent changes the concurrency cap; lowering it lets running
+// downloads finish but starts fewer new ones.
+func (q *DownloadQueue) SetMaxConcurrent(n int) {
+	if n <= 0 {
+		n = defaultMaxConcurrent
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.max = n
+	q.dispatchLocked()
+}
+
+// SetSmallMaxConcurrent changes the small-file lane's concurrency cap.
+func (q *DownloadQueue) SetSmallMaxConcurrent(n int) {
+	if n <= 0 {
+		n = defaultSmallMaxConcurrent
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.smallMax = n
+	q.dispatchLocked()
+}
+
+// SetPaused stops dispatching new downloads while true; running downloads
+// finish normally. Unpausing dispatches whatever accumulated.
+func (q *DownloadQueue) SetPaused(paused bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.paused = paused
+	if !paused {
+		q.dispatchLocked()
+	}
+}
+
+// SetPriority re-prioritises a queued task. Running or finished tasks are
+// left alone.
+func (q *DownloadQueue) SetPriority(taskID string, priority int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, item := range q.pending {
+		if item.task.ID == taskID {
+			item.priority = priority
+			q.sortLocked()
+			q.dispatchLocked()
+			return true
+		}
+	}
+	return false
+}
+
+// Remove drops a task from the pending queue before it starts.
+func (q *DownloadQueue) Remove(taskID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, item := range q.pending {
+		if item.task.ID == taskID {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// State returns a snapshot of the queue for the frontend.
+func (q *DownloadQueue) State() QueueState {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	state := QueueState{MaxConcurrent: q.max, SmallMaxConcurrent: q.smallMax}
+	for _, item := range q.pending {
+		state.Queued = append(state.Queued, item.task)
+	}
+	for _, item := range q.active {
+		state.Active = append(state.Active, item.task)
+	}
+	state.Finished = append(state.Finished, q.finished...)
+	return state
+}
+
+func (q *DownloadQueue) sortLocked() {
+	sort.SliceStable(q.pending, func(i, j int) bool {
+		if q.pending[i].priority != q.pending[j].priority {
+			return q.pending[i].priority > q.pending[j].priority
+		}
+		return q.pending[i].seq < q.pending[j].seq
+	})
+}
+
+// dispatchLocked starts pending jobs while their lane has a free slot.
+// Small files (known size under smallFileThreshold) occupy the small lane;
+// everything else, including tasks with unknown size, uses the big lane.
+func (q *DownloadQueue) dispatchLocked() {
+	if q.paused {
+		return
+	}
+	big, small := 0, 0
+	for _, item := range q.active {
+		if item.small {
+			small++
+		} else {
+			big++
+		}
+	}
+	rest := q.pending[:0]
+	for _, item := range q.pending {
+		switch q.depStateLocked(item) {
+		case depWaiting:
+			rest = append(rest, item)
+			continue
+		case depFailed:
+			item.task.setStatus("failed")
+			item.task.setError("dependency failed")
+			q.outcome[item.task.ID] = "failed"
+			q.finished = append(q.finished, item.task)
+			if q.emit != nil {
+				q.emit("task:failed", item.task)
+			}
+			continue
+		}
+		_, size := item.task.fileMeta()
+		isSmall := size > 0 && size < smallFileThreshold
+		if isSmall && small < q.smallMax {
+			item.small = true
+			small++
+		} else if !isSmall && big < q.max {
+			big++
+		} else {
+			rest = append(rest, item)
+			continue
+		}
+		q.active[item.task.ID] = item
+		go q.runItem(item)
+	}
+	q.pending = rest
+}
+
+const (
+	depReady = iota
+	depWaiting
+	depFailed
+)
+
+// depStateLocked reports whether an item's dependencies are all done,
+// still in flight, or ended in failure. Unknown IDs count as in flight so
+// a dependency enqueued a moment later still works.
+func (q *DownloadQueue) depStateLocked(item *queueItem) int {
+	for _, dep := range item.deps {
+		switch q.outcome[dep] {
+		case "done":
+		case "failed", "cancelled":
+			return depFailed
+		default:
+			return depWaiting
+		}
+	}
+	return depReady
+}
+
+func (q *DownloadQueue) runItem(item *queueItem) {
+	item.run(context.Background())
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.active, item.task.ID)
+	q.outcome[item.task.ID] = item.task.status()
+	q.finished = append(q.finished, item.task)
+	q.dispatchLocked()
+}
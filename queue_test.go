@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testQueueTask builds a task the way the queue sees one: already queued,
+// with only the fields dispatch looks at filled in.
+func testQueueTask(id string, size int64) *DownloadTask {
+	return &DownloadTask{ID: id, Size: size, Status: "queued"}
+}
+
+// waitRun receives one task ID from starts or fails the test.
+func waitRun(t *testing.T, starts <-chan string) string {
+	t.Helper()
+	select {
+	case id := <-starts:
+		return id
+	case <-time.After(5 * time.Second):
+		t.Fatal("no task started in time")
+		return ""
+	}
+}
+
+func TestQueuePriorityOrder(t *testing.T) {
+	q := NewDownloadQueue(1)
+	release := make(chan struct{})
+	starts := make(chan string, 3)
+	run := func(task *DownloadTask) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			starts <- task.ID
+			<-release
+			task.setStatus("done")
+			return nil
+		}
+	}
+
+	blocker := testQueueTask("blocker", 0)
+	q.Enqueue(blocker, 0, run(blocker))
+	if waitRun(t, starts) != "blocker" {
+		t.Fatal("blocker did not start first")
+	}
+
+	low := testQueueTask("low", 0)
+	high := testQueueTask("high", 0)
+	q.Enqueue(low, 0, run(low))
+	q.Enqueue(high, 5, run(high))
+	close(release)
+
+	if got := waitRun(t, starts); got != "high" {
+		t.Errorf("after blocker, %q started, want high", got)
+	}
+	if got := waitRun(t, starts); got != "low" {
+		t.Errorf("last start was %q, want low", got)
+	}
+}
+
+func TestQueueSmallFileLane(t *testing.T) {
+	q := NewDownloadQueue(1)
+	release := make(chan struct{})
+	defer close(release)
+	starts := make(chan string, 2)
+	run := func(task *DownloadTask) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			starts <- task.ID
+			<-release
+			task.setStatus("done")
+			return nil
+		}
+	}
+
+	big := testQueueTask("big", 0) // unknown size uses the big lane
+	q.Enqueue(big, 0, run(big))
+	if waitRun(t, starts) != "big" {
+		t.Fatal("big task did not start")
+	}
+
+	// The single big slot is occupied, but a small file rides its own lane.
+	small := testQueueTask("small", 1024)
+	q.Enqueue(small, 0, run(small))
+	if got := waitRun(t, starts); got != "small" {
+		t.Errorf("%q started, want small to bypass the full big lane", got)
+	}
+}
+
+func TestQueueDependencyDispatch(t *testing.T) {
+	q := NewDownloadQueue(3)
+	starts := make(chan string, 2)
+
+	dependent := testQueueTask("dependent", 0)
+	q.EnqueueAfter(dependent, 0, []string{"dep"}, func(ctx context.Context) error {
+		starts <- dependent.ID
+		dependent.setStatus("done")
+		return nil
+	})
+	if state := q.State(); len(state.Queued) != 1 || len(state.Active) != 0 {
+		t.Fatalf("dependent dispatched before its dependency: %d queued, %d active", len(state.Queued), len(state.Active))
+	}
+
+	dep := testQueueTask("dep", 0)
+	q.Enqueue(dep, 0, func(ctx context.Context) error {
+		starts <- dep.ID
+		dep.setStatus("done")
+		return nil
+	})
+	if got := waitRun(t, starts); got != "dep" {
+		t.Fatalf("%q started, want dep", got)
+	}
+	if got := waitRun(t, starts); got != "dependent" {
+		t.Errorf("%q started after dep finished, want dependent", got)
+	}
+}
+
+func TestQueueDependencyFailure(t *testing.T) {
+	q := NewDownloadQueue(3)
+	dep := testQueueTask("dep", 0)
+	q.Enqueue(dep, 0, func(ctx context.Context) error {
+		dep.setStatus("failed")
+		return nil
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for len(q.State().Finished) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("dependency never finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	dependent := testQueueTask("dependent", 0)
+	q.EnqueueAfter(dependent, 0, []string{"dep"}, func(ctx context.Context) error {
+		t.Error("dependent ran despite failed dependency")
+		return nil
+	})
+	if got := dependent.status(); got != "failed" {
+		t.Errorf("dependent status = %q, want failed", got)
+	}
+	if got := dependent.errText(); got != "dependency failed" {
+		t.Errorf("dependent error = %q, want dependency failed", got)
+	}
+	if got := len(q.State().Finished); got != 2 {
+		t.Errorf("%d finished tasks, want 2", got)
+	}
+}
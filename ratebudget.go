@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// MethodBudget is one method's view of the rate budget: how much has been
+// asked of it this session and how much more is likely safe.
+type MethodBudget struct {
+	Method          string `json:"method"`
+	Requests        int64  `json:"requests"`
+	FloodWaits      int64  `json:"floodWaits"`
+	LastWaitSeconds int    `json:"lastWaitSeconds,omitempty"`
+	// SinceLastWait counts requests since the last flood wait (or session
+	// start when none happened).
+	SinceLastWait int64 `json:"sinceLastWait"`
+	// SafeBudget estimates how many more requests fit before the next
+	// wait: the smallest gap observed between waits, minus what this
+	// stretch already used. -1 until a wait has been observed, since no
+	// ceiling is known yet.
+	SafeBudget int64 `json:"safeBudget"`
+}
+
+// rateTracker observes every raw API call and the FLOOD_WAITs among them,
+// per method, to derive the budget estimates.
+type rateTracker struct {
+	mu      sync.Mutex
+	methods map[string]*methodStats
+}
+
+type methodStats struct {
+	requests   int64
+	floodWaits int64
+	lastWait   int
+	sinceWait  int64
+	// minGap is the smallest request count that ran between two flood
+	// waits — the tightest ceiling the server has shown for this method.
+	minGap int64
+}
+
+func newRateTracker() *rateTracker {
+	return &rateTracker{methods: make(map[string]*methodStats)}
+}
+
+// note records one call and its outcome.
+func (t *rateTracker) note(method string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.methods[method]
+	if st == nil {
+		st = &methodStats{}
+		t.methods[method] = st
+	}
+	st.requests++
+	st.sinceWait++
+	if wait, ok := tgerr.AsFloodWait(err); ok {
+		st.floodWaits++
+		st.lastWait = int(wait.Seconds())
+		if st.minGap == 0 || st.sinceWait < st.minGap {
+			st.minGap = st.sinceWait
+		}
+		st.sinceWait = 0
+	}
+}
+
+// budgets snapshots the tracker sorted by method name.
+func (t *rateTracker) budgets() []MethodBudget {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]MethodBudget, 0, len(t.methods))
+	for method, st := range t.methods {
+		b := MethodBudget{
+			Method:          method,
+			Requests:        st.requests,
+			FloodWaits:      st.floodWaits,
+			LastWaitSeconds: st.lastWait,
+			SinceLastWait:   st.sinceWait,
+			SafeBudget:      -1,
+		}
+		if st.minGap > 0 {
+			if b.SafeBudget = st.minGap - st.sinceWait; b.SafeBudget < 0 {
+				b.SafeBudget = 0
+			}
+		}
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Method < out[j].Method })
+	return out
+}
+
+// Handle implements telegram.Middleware, counting every invocation under
+// its TL method name. The tracker sits between the flood-wait waiter and
+// the wire, so it sees every attempt and every FLOOD_WAIT the server
+// returned — including the ones the waiter then sleeps out, which never
+// surface as errors but matter for pacing a crawl.
+func (t *rateTracker) Handle(next tg.Invoker) telegram.InvokeFunc {
+	return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+		err := next.Invoke(ctx, input, output)
+		if obj, ok := input.(interface{ TypeName() string }); ok {
+			t.note(obj.TypeName(), err)
+		}
+		return err
+	}
+}
+
+// GetRateBudget returns the active account's per-method request counts,
+// observed flood waits and the estimated remaining safe budget, so a
+// crawl's pace can be judged before Telegram pushes back.
+func (a *App) GetRateBudget() []MethodBudget {
+	b := a.active()
+	if b == nil {
+		return nil
+	}
+	return b.client.budget.budgets()
+}
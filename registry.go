@@ -0,0 +1,79 @@
+package main
+
+import "sync"
+
+// taskRegistry is the one home for task lifecycle state. Bindings and
+// download goroutines share it, so all access goes through its own lock
+// instead of piggybacking on the App mutex. Finished and cancelled tasks
+// stay registered — and therefore visible to the UI — until DiscardTask
+// or ClearCompleted removes them.
+type taskRegistry struct {
+	mu    sync.Mutex
+	tasks map[string]*DownloadTask
+}
+
+func newTaskRegistry() *taskRegistry {
+	return &taskRegistry{tasks: make(map[string]*DownloadTask)}
+}
+
+// Add registers a task under its ID.
+func (r *taskRegistry) Add(task *DownloadTask) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tasks[task.ID] = task
+}
+
+// Get returns the task with the given ID.
+func (r *taskRegistry) Get(id string) (*DownloadTask, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	task, ok := r.tasks[id]
+	return task, ok
+}
+
+// List returns all registered tasks in no particular order.
+func (r *taskRegistry) List() []*DownloadTask {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]*DownloadTask, 0, len(r.tasks))
+	for _, task := range r.tasks {
+		out = append(out, task)
+	}
+	return out
+}
+
+// Remove unregisters and returns the task with the given ID.
+func (r *taskRegistry) Remove(id string) (*DownloadTask, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	task, ok := r.tasks[id]
+	delete(r.tasks, id)
+	return task, ok
+}
+
+// ClearCompleted drops every task that reached a terminal state and
+// returns how many were removed.
+func (r *taskRegistry) ClearCompleted() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for id, task := range r.tasks {
+		switch task.status() {
+		case "done", "failed", "cancelled", "expired":
+			delete(r.tasks, id)
+			n++
+		}
+	}
+	return n
+}
+
+// Snapshot returns a copy of the registry map, for persistence.
+func (r *taskRegistry) Snapshot() map[string]*DownloadTask {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[string]*DownloadTask, len(r.tasks))
+	for id, task := range r.tasks {
+		out[id] = task
+	}
+	return out
+}
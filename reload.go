@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileSettings is the optional settings.json in the state directory. It
+// covers the knobs worth changing on a running instance; fields left out
+// of the file keep their current value. Flag and environment overrides
+// still win after every reload, per the precedence in overrides.go.
+type fileSettings struct {
+	GlobalLimit     *int64      `json:"speedLimit,omitempty"`
+	BackgroundLimit *int64      `json:"bgSpeedLimit,omitempty"`
+	DestDir         *string     `json:"destDir,omitempty"`
+	Quality         *string     `json:"quality,omitempty"`
+	NameTemplate    *string     `json:"nameTemplate,omitempty"`
+	WatchRules      []WatchRule `json:"watchRules,omitempty"`
+}
+
+const settingsName = "settings.json"
+
+// settingsPollInterval is how often the settings file is checked for
+// changes. Polling keeps the desktop and headless builds dependency-free;
+// a few seconds of latency is fine for a config edit.
+const settingsPollInterval = 3 * time.Second
+
+func settingsPath(dir string) string {
+	return filepath.Join(dir, settingsName)
+}
+
+// loadSettings reads the settings file; a missing file is not an error,
+// it just means nothing to apply.
+func loadSettings(dir string) (*fileSettings, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(settingsPath(dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s fileSettings
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", settingsName, err)
+	}
+	return &s, nil
+}
+
+// applySettings pushes file values into the running instance. Limiter
+// rates take effect mid-transfer, watch rules swap atomically, and the
+// destination only affects tasks queued afterwards — nothing in flight is
+// dropped.
+func (a *App) applySettings(s *fileSettings) {
+	if s == nil {
+		return
+	}
+	if s.GlobalLimit != nil {
+		a.globalLimit.SetRate(*s.GlobalLimit)
+	}
+	if s.BackgroundLimit != nil {
+		a.bgLimit.SetRate(*s.BackgroundLimit)
+	}
+	a.mu.Lock()
+	if s.DestDir != nil {
+		a.destDir = *s.DestDir
+	}
+	if s.Quality != nil {
+		a.quality = *s.Quality
+	}
+	if s.NameTemplate != nil {
+		a.nameTemplate = *s.NameTemplate
+	}
+	bundles := make([]*accountBundle, 0, len(a.bundles))
+	for _, b := range a.bundles {
+		bundles = append(bundles, b)
+	}
+	a.mu.Unlock()
+	if s.WatchRules != nil {
+		for _, b := range bundles {
+			b.watcher.SetRules(append([]WatchRule(nil), s.WatchRules...))
+		}
+	}
+	// Flags and environment variables outrank the file.
+	a.applyOverrides()
+}
+
+// ReloadConfig re-reads settings.json and applies it immediately, for
+// callers that don't want to wait out the file poll.
+func (a *App) ReloadConfig() Response {
+	s, err := loadSettings(a.baseDir)
+	if err != nil {
+		return respError(err)
+	}
+	if s == nil {
+		return respErr(CodeNotFound, "no "+settingsName+" in "+a.baseDir)
+	}
+	a.applySettings(s)
+	a.audit.Record("settings", "reloaded "+settingsName)
+	a.emit("config:reloaded", settingsPath(a.baseDir))
+	return respOK()
+}
+
+// watchSettings applies settings.json on edit, keyed off the file's
+// modification time. Started from startup alongside the deadline watcher.
+func (a *App) watchSettings(ctx context.Context) {
+	if a.baseDir == "" {
+		return
+	}
+	var lastMod time.Time
+	if info, err := os.Stat(settingsPath(a.baseDir)); err == nil {
+		lastMod = info.ModTime()
+	}
+	ticker := time.NewTicker(settingsPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			info, err := os.Stat(settingsPath(a.baseDir))
+			if err != nil || !info.ModTime().After(lastMod) {
+				continue
+			}
+			lastMod = info.ModTime()
+			s, err := loadSettings(a.baseDir)
+			if err != nil {
+				logErrorf("settings: %v", err)
+				continue
+			}
+			a.applySettings(s)
+			a.emit("config:reloaded", settingsPath(a.baseDir))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
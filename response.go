@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/gotd/td/tgerr"
+)
+
+// Response is the structured result of every state-changing binding. The
+// frontend switches on Code instead of string-matching messages; Message
+// is human-readable and Details carries call-specific payloads such as a
+// created task ID or a flood-wait duration.
+type Response struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Stable result codes. Frontends must treat unknown codes as errors, so
+// new ones can be added without breaking old UIs.
+const (
+	CodeOK               = "OK"
+	CodeCodeSent         = "CODE_SENT"
+	CodePending          = "PENDING"
+	CodeNotConnected     = "NOT_CONNECTED"
+	CodeNotAuthenticated = "NOT_AUTHENTICATED"
+	CodeFloodWait        = "FLOOD_WAIT"
+	CodeLinkInvalid      = "LINK_INVALID"
+	CodeNotFound         = "NOT_FOUND"
+	CodeConflict         = "CONFLICT"
+	CodeInvalidArgument  = "INVALID_ARGUMENT"
+	CodeDiskFull         = "DISK_FULL"
+	CodeInternal         = "INTERNAL"
+)
+
+// OK reports whether the response is a success.
+func (r Response) OK() bool {
+	return r.Code == CodeOK || r.Code == CodeCodeSent
+}
+
+func respOK() Response {
+	return Response{Code: CodeOK}
+}
+
+// respID is the success response of bindings that create something; the
+// new ID rides in Details.
+func respID(id string) Response {
+	return Response{Code: CodeOK, Details: id}
+}
+
+func respErr(code, message string) Response {
+	return Response{Code: code, Message: message}
+}
+
+// respError classifies an error into a stable code. Telegram rate limits
+// and disk exhaustion get their own codes with details; everything else
+// is INTERNAL with the error text as the message.
+func respError(err error) Response {
+	if wait, ok := tgerr.AsFloodWait(err); ok {
+		return Response{Code: CodeFloodWait, Message: err.Error(), Details: int(wait.Seconds())}
+	}
+	var df *diskFullError
+	if errors.As(err, &df) {
+		return Response{Code: CodeDiskFull, Message: err.Error()}
+	}
+	if tgerr.Is(err, "AUTH_KEY_UNREGISTERED", "SESSION_REVOKED", "USER_DEACTIVATED") {
+		return Response{Code: CodeNotAuthenticated, Message: err.Error()}
+	}
+	return Response{Code: CodeInternal, Message: err.Error()}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// scheduleTick is how often the scheduler re-evaluates the active window.
+const scheduleTick = time.Minute
+
+// scheduleName is the state document holding the bandwidth schedule.
+const scheduleName = "schedule.json"
+
+// ScheduleWindow is one daily time window with its own bandwidth rule.
+// Times are local "HH:MM"; a window whose end is before its start wraps
+// past midnight (e.g. 23:00–06:00).
+type ScheduleWindow struct {
+	Start            string `json:"start"`
+	End              string `json:"end"`
+	LimitBytesPerSec int64  `json:"limitBytesPerSec"` // 0 = unlimited inside the window
+	PauseQueue       bool   `json:"pauseQueue"`       // stop dispatching new downloads entirely
+}
+
+// BandwidthSchedule is the persisted time-of-day bandwidth configuration.
+// Outside every window the default limit applies.
+type BandwidthSchedule struct {
+	Enabled                 bool             `json:"enabled"`
+	DefaultLimitBytesPerSec int64            `json:"defaultLimitBytesPerSec"`
+	Windows                 []ScheduleWindow `json:"windows"`
+}
+
+// bandwidthScheduler applies a BandwidthSchedule over time: a goroutine
+// re-evaluates the active window once a minute and adjusts the global
+// speed limit and queue pause state through the callbacks it was built
+// with.
+type bandwidthScheduler struct {
+	mu       sync.Mutex
+	schedule BandwidthSchedule
+	store    stateStore
+
+	setLimit func(bytesPerSec int64)
+	setPause func(paused bool)
+
+	lastLimit  int64
+	lastPause  bool
+	haveActive bool
+}
+
+// newBandwidthScheduler loads any persisted schedule and starts the
+// evaluation loop. The callbacks apply the computed limit and pause state.
+func newBandwidthScheduler(dir string, setLimit func(int64), setPause func(bool)) *bandwidthScheduler {
+	s := &bandwidthScheduler{setLimit: setLimit, setPause: setPause}
+	if dir != "" {
+		s.store = stateStoreFor(dir)
+		if data, err := s.store.Load(scheduleName); err == nil && data != nil {
+			if err := json.Unmarshal(data, &s.schedule); err != nil {
+				logErrorf("schedule: load: %v", err)
+			}
+		}
+	}
+	go s.loop()
+	return s
+}
+
+// Set replaces the schedule, persists it and applies it immediately.
+func (s *bandwidthScheduler) Set(schedule BandwidthSchedule) error {
+	for _, w := range schedule.Windows {
+		if _, err := parseClock(w.Start); err != nil {
+			return fmt.Errorf("window start: %w", err)
+		}
+		if _, err := parseClock(w.End); err != nil {
+			return fmt.Errorf("window end: %w", err)
+		}
+	}
+	s.mu.Lock()
+	s.schedule = schedule
+	s.haveActive = false // force re-apply
+	s.saveLocked()
+	s.mu.Unlock()
+	s.evaluate(time.Now())
+	return nil
+}
+
+// Get returns the current schedule.
+func (s *bandwidthScheduler) Get() BandwidthSchedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.schedule
+}
+
+func (s *bandwidthScheduler) loop() {
+	s.evaluate(time.Now())
+	ticker := time.NewTicker(scheduleTick)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		s.evaluate(now)
+	}
+}
+
+// evaluate computes the rule for the given time and applies it if it
+// changed since the last application.
+func (s *bandwidthScheduler) evaluate(now time.Time) {
+	s.mu.Lock()
+	if !s.schedule.Enabled {
+		if s.haveActive {
+			// Schedule switched off: drop back to no limit, unpaused.
+			s.haveActive = false
+			s.mu.Unlock()
+			s.setLimit(0)
+			s.setPause(false)
+			return
+		}
+		s.mu.Unlock()
+		return
+	}
+	limit := s.schedule.DefaultLimitBytesPerSec
+	pause := false
+	minute := now.Hour()*60 + now.Minute()
+	for _, w := range s.schedule.Windows {
+		if windowContains(w, minute) {
+			limit = w.LimitBytesPerSec
+			pause = w.PauseQueue
+			break
+		}
+	}
+	changed := !s.haveActive || limit != s.lastLimit || pause != s.lastPause
+	s.lastLimit, s.lastPause, s.haveActive = limit, pause, true
+	s.mu.Unlock()
+	if changed {
+		s.setLimit(limit)
+		s.setPause(pause)
+	}
+}
+
+// windowContains reports whether the minute-of-day falls inside the
+// window, handling windows that wrap past midnight.
+func windowContains(w ScheduleWindow, minute int) bool {
+	start, err1 := parseClock(w.Start)
+	end, err2 := parseClock(w.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// parseClock converts "HH:MM" to minutes since midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("bad time %q (want HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (s *bandwidthScheduler) saveLocked() {
+	if s.store == nil {
+		return
+	}
+	data, err := json.MarshalIndent(s.schedule, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := s.store.Store(scheduleName, data); err != nil {
+		logErrorf("schedule: save: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestParseClock(t *testing.T) {
+	cases := []struct {
+		in   string
+		want int
+	}{
+		{"00:00", 0},
+		{"09:30", 9*60 + 30},
+		{"23:59", 23*60 + 59},
+	}
+	for _, tc := range cases {
+		got, err := parseClock(tc.in)
+		if err != nil {
+			t.Errorf("parseClock(%q): %v", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parseClock(%q) = %d, want %d", tc.in, got, tc.want)
+		}
+	}
+	for _, in := range []string{"", "24:00", "9:3am", "noon"} {
+		if got, err := parseClock(in); err == nil {
+			t.Errorf("parseClock(%q) = %d, want error", in, got)
+		}
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	day := ScheduleWindow{Start: "09:00", End: "17:00"}
+	night := ScheduleWindow{Start: "23:00", End: "06:00"} // wraps past midnight
+	cases := []struct {
+		name   string
+		w      ScheduleWindow
+		minute int
+		want   bool
+	}{
+		{name: "inside day window", w: day, minute: 12 * 60, want: true},
+		{name: "at start", w: day, minute: 9 * 60, want: true},
+		{name: "end is exclusive", w: day, minute: 17 * 60, want: false},
+		{name: "before day window", w: day, minute: 8 * 60, want: false},
+		{name: "wrapped, late evening", w: night, minute: 23*60 + 30, want: true},
+		{name: "wrapped, early morning", w: night, minute: 2 * 60, want: true},
+		{name: "wrapped, end is exclusive", w: night, minute: 6 * 60, want: false},
+		{name: "wrapped, midday outside", w: night, minute: 12 * 60, want: false},
+		{name: "bad clock never matches", w: ScheduleWindow{Start: "nine", End: "17:00"}, minute: 12 * 60, want: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := windowContains(tc.w, tc.minute); got != tc.want {
+				t.Errorf("windowContains(%s-%s, %d) = %v, want %v", tc.w.Start, tc.w.End, tc.minute, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// dispatchTick is how often scheduled tasks are checked for dispatch.
+const dispatchTick = 15 * time.Second
+
+// wallClockLayout is the zone-less time format used together with an IANA
+// timezone name; the zone supplies the offset in force on that date.
+const wallClockLayout = "2006-01-02T15:04:05"
+
+// parseStartAt resolves a schedule time. With a timezone the time is a
+// wall-clock reading in that IANA zone ("2026-11-05T02:30:00" in
+// "America/New_York"), resolved with the offset in force on the target
+// date — so a schedule made during summer time still fires at the right
+// local hour after the clocks change. Without a timezone startAt must be
+// full RFC 3339 and names a fixed instant.
+func parseStartAt(startAt, timezone string) (time.Time, error) {
+	if timezone == "" {
+		return time.Parse(time.RFC3339, startAt)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timezone %q: %w", timezone, err)
+	}
+	when, err := time.ParseInLocation(wallClockLayout, startAt, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("with a timezone, startAt must be wall-clock %q: %w", wallClockLayout, err)
+	}
+	return when, nil
+}
+
+// setSchedule stores a resolved schedule on the task, including the
+// display form rendered in the schedule's own zone.
+func setSchedule(task *DownloadTask, when time.Time, timezone string) {
+	task.mu.Lock()
+	defer task.mu.Unlock()
+	task.StartAt = when
+	task.Timezone = timezone
+	task.NextStart = when.Format(time.RFC3339)
+}
+
+// ScheduleDownload creates a task that stays in the "scheduled" state
+// until startAt, then enters the queue like any other task, so large
+// grabs can run overnight. startAt and timezone follow parseStartAt;
+// timezone may be "". Returns the task ID.
+func (a *App) ScheduleDownload(link, destDir, account, startAt, timezone string) Response {
+	b := a.bundleFor(account)
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	when, err := parseStartAt(startAt, timezone)
+	if err != nil {
+		return respError(err)
+	}
+	info, err := ParseLink(link)
+	if err != nil {
+		return respError(err)
+	}
+	if info.InviteHash != "" {
+		return respErr(CodeLinkInvalid, "invite link; join the channel first via JoinChannel")
+	}
+	task := a.newTask(info, destDir, b.name)
+	task.setStatus("scheduled")
+	setSchedule(task, when, timezone)
+	a.audit.Record("queue", fmt.Sprintf("%s %s scheduled for %s", task.ID, link, when.Format(time.RFC3339)))
+	a.emit("task:scheduled", task)
+	return respID(task.ID)
+}
+
+// SetTaskSchedule moves a queued task to the scheduled state with a new
+// start time, or updates a scheduled one. An empty startAt clears the
+// schedule and queues the task immediately.
+func (a *App) SetTaskSchedule(id, startAt, timezone string) Response {
+	task, ok := a.tasks.Get(id)
+	if !ok {
+		return respErr(CodeNotFound, "no such task "+id)
+	}
+	status := task.status()
+	if status != "queued" && status != "scheduled" {
+		return respErr(CodeConflict, "task is "+status)
+	}
+	if startAt == "" {
+		if status == "scheduled" {
+			task.mu.Lock()
+			task.StartAt = time.Time{}
+			task.Timezone = ""
+			task.NextStart = ""
+			task.mu.Unlock()
+			return a.dispatchScheduled(task)
+		}
+		return respOK()
+	}
+	when, err := parseStartAt(startAt, timezone)
+	if err != nil {
+		return respError(err)
+	}
+	if status == "queued" && !a.queue.Remove(id) {
+		return respErr(CodeConflict, "task already dispatched")
+	}
+	task.setStatus("scheduled")
+	setSchedule(task, when, timezone)
+	a.emit("task:scheduled", task)
+	return respOK()
+}
+
+// dispatchScheduled hands a scheduled task to the queue on its account.
+func (a *App) dispatchScheduled(task *DownloadTask) Response {
+	b := a.bundleFor(task.Account)
+	if b == nil {
+		return respErr(CodeNotConnected, "account "+task.Account+" is not connected")
+	}
+	task.setStatus("queued")
+	a.queue.Enqueue(task, 0, func(ctx context.Context) error {
+		return b.downloader.Run(ctx, task)
+	})
+	return respID(task.ID)
+}
+
+// watchSchedules dispatches scheduled tasks whose start time has come.
+// Tasks whose account is not connected yet stay scheduled and are retried
+// on the next tick, which also covers schedules restored from disk before
+// their account reconnects.
+func (a *App) watchSchedules(ctx context.Context) {
+	ticker := time.NewTicker(dispatchTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, task := range a.tasks.List() {
+				startAt := task.startTime()
+				if task.status() != "scheduled" || startAt.IsZero() || now.Before(startAt) {
+					continue
+				}
+				if res := a.dispatchScheduled(task); !res.OK() {
+					logWarnf("schedule %s: %s", task.ID, res.Message)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,348 @@
+//go:build headless
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// apiServer exposes the App bindings over plain REST for the headless
+// build. It reuses the bindings verbatim, so desktop and server behave
+// identically; responses are the same structured Response values the
+// frontend gets. With profiles configured, every request selects its
+// isolated App by API token; otherwise a single App serves everything,
+// guarded by the -api-token / TELETURBO_API_TOKEN token when one is set.
+type apiServer struct {
+	single *App
+	// token guards single-App mode; empty leaves the API open, for
+	// localhost-only setups.
+	token    string
+	profiles map[string]*App // token -> profile App
+
+	mu     sync.Mutex
+	subs   map[chan apiEvent]*App
+	wsSubs map[chan wsEvent]*App
+	rings  map[*App]*eventRing
+}
+
+// apiEvent is one app event as delivered on the /events stream.
+type apiEvent struct {
+	Event string      `json:"event"`
+	Data  interface{} `json:"data"`
+}
+
+// newAPIServer serves one shared App (single-user mode).
+func newAPIServer(app *App) *apiServer {
+	s := &apiServer{
+		single: app,
+		token:  overrides.APIToken,
+		subs:   make(map[chan apiEvent]*App),
+		wsSubs: make(map[chan wsEvent]*App),
+		rings:  make(map[*App]*eventRing),
+	}
+	eventSink = s.publish
+	return s
+}
+
+// newProfileAPIServer serves one isolated App per token.
+func newProfileAPIServer(profiles map[string]*App) *apiServer {
+	s := &apiServer{
+		profiles: profiles,
+		subs:     make(map[chan apiEvent]*App),
+		wsSubs:   make(map[chan wsEvent]*App),
+		rings:    make(map[*App]*eventRing),
+	}
+	eventSink = s.publish
+	return s
+}
+
+// appFor resolves the request to its App. In profile mode the token comes
+// from "Authorization: Bearer <token>" or the X-API-Token header; an
+// unknown token gets nil and the caller answers 401.
+func (s *apiServer) appFor(r *http.Request) *App {
+	return s.appForToken(requestToken(r))
+}
+
+// appForToken resolves an already-extracted token, for callers like the
+// JSON-RPC shim whose token travels inside the request body.
+func (s *apiServer) appForToken(token string) *App {
+	if s.single != nil {
+		if s.token != "" && token != s.token {
+			return nil
+		}
+		return s.single
+	}
+	return s.profiles[token]
+}
+
+// requestToken extracts the API token from a request. The ?token= query
+// form exists for WebSocket clients, whose browsers cannot set headers on
+// the upgrade request; header forms are preferred everywhere else.
+func requestToken(r *http.Request) string {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.Header.Get("X-API-Token")
+	}
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	return token
+}
+
+// apps returns every served App, for health checks and shutdown.
+func (s *apiServer) apps() []*App {
+	if s.single != nil {
+		return []*App{s.single}
+	}
+	out := make([]*App, 0, len(s.profiles))
+	for _, app := range s.profiles {
+		out = append(out, app)
+	}
+	return out
+}
+
+func (s *apiServer) listen(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleIndex)
+	mux.HandleFunc("/tasks", s.handleTasks)
+	mux.HandleFunc("/tasks/", s.handleTaskAction)
+	mux.HandleFunc("/accounts", s.handleAccounts)
+	mux.HandleFunc("/analytics", s.handleAnalytics)
+	mux.HandleFunc("/capabilities", s.handleCapabilities)
+	mux.HandleFunc("/history", s.handleHistory)
+	mux.HandleFunc("/jsonrpc", s.handleJSONRPC)
+	mux.HandleFunc("/audit", s.handleAudit)
+	mux.HandleFunc("/events", s.handleEvents)
+	mux.HandleFunc("/ws", s.handleWS)
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	// State-changing API calls land in the audit log with the token that
+	// made them, before the handler runs, so calls that later fail are on
+	// record too.
+	return http.ListenAndServe(addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			if app := s.appFor(r); app != nil {
+				app.audit.RecordRemote(r.Method+" "+r.URL.Path, "", requestToken(r))
+			}
+		}
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+// handleCapabilities returns the build's capability manifest.
+func (s *apiServer) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	app := s.appFor(r)
+	if app == nil {
+		http.Error(w, "unknown or missing API token", http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, app.GetCapabilities())
+}
+
+// handleHistory returns finished-transfer records for scripts that want
+// raw history rather than the /analytics aggregates; ?limit= bounds the
+// count, default 100.
+func (s *apiServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	app := s.appFor(r)
+	if app == nil {
+		http.Error(w, "unknown or missing API token", http.StatusUnauthorized)
+		return
+	}
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	writeJSON(w, http.StatusOK, app.GetTransferHistory(limit))
+}
+
+// handleAudit returns the most recent control actions; ?limit= bounds the
+// count, default 100.
+func (s *apiServer) handleAudit(w http.ResponseWriter, r *http.Request) {
+	app := s.appFor(r)
+	if app == nil {
+		http.Error(w, "unknown or missing API token", http.StatusUnauthorized)
+		return
+	}
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	writeJSON(w, http.StatusOK, app.GetAuditLog(limit))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// result writes a binding Response; error codes become a 4xx.
+func result(w http.ResponseWriter, res Response) {
+	status := http.StatusOK
+	if !res.OK() {
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, res)
+}
+
+func (s *apiServer) handleTasks(w http.ResponseWriter, r *http.Request) {
+	app := s.appFor(r)
+	if app == nil {
+		http.Error(w, "unknown or missing API token", http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, app.ListIncompleteTasks())
+	case http.MethodPost:
+		var req struct {
+			Link     string `json:"link"`
+			DestDir  string `json:"destDir"`
+			Account  string `json:"account"`
+			Priority int    `json:"priority"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			result(w, respErr(CodeInvalidArgument, err.Error()))
+			return
+		}
+		result(w, app.QueueDownload(req.Link, req.DestDir, req.Account, req.Priority))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTaskAction routes /tasks/<id>/<pause|resume|cancel> and
+// DELETE /tasks/<id>.
+func (s *apiServer) handleTaskAction(w http.ResponseWriter, r *http.Request) {
+	app := s.appFor(r)
+	if app == nil {
+		http.Error(w, "unknown or missing API token", http.StatusUnauthorized)
+		return
+	}
+	rest := strings.TrimPrefix(r.URL.Path, "/tasks/")
+	id, action, _ := strings.Cut(rest, "/")
+	if id == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method == http.MethodDelete && action == "" {
+		result(w, app.DiscardTask(id))
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	switch action {
+	case "pause":
+		result(w, app.PauseDownload(id))
+	case "resume":
+		result(w, app.ResumeDownload(id))
+	case "cancel":
+		result(w, app.CancelDownload(id))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *apiServer) handleAccounts(w http.ResponseWriter, r *http.Request) {
+	app := s.appFor(r)
+	if app == nil {
+		http.Error(w, "unknown or missing API token", http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, app.ListAccounts())
+	case http.MethodPost:
+		var req struct {
+			Name    string `json:"name"`
+			AppID   int    `json:"appId"`
+			AppHash string `json:"appHash"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			result(w, respErr(CodeInvalidArgument, err.Error()))
+			return
+		}
+		result(w, app.AddAccount(req.Name, req.AppID, req.AppHash))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *apiServer) handleAnalytics(w http.ResponseWriter, r *http.Request) {
+	app := s.appFor(r)
+	if app == nil {
+		http.Error(w, "unknown or missing API token", http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, app.GetAnalytics())
+}
+
+// handleEvents streams app events as server-sent events, the headless
+// stand-in for the Wails event bus.
+func (s *apiServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	app := s.appFor(r)
+	if app == nil {
+		http.Error(w, "unknown or missing API token", http.StatusUnauthorized)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	ch := make(chan apiEvent, 64)
+	s.mu.Lock()
+	s.subs[ch] = app
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, ch)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case ev := <-ch:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// publish fans an event out to the emitting App's /events subscribers;
+// profiles never see each other's events, and slow consumers drop events
+// rather than block downloads.
+func (s *apiServer) publish(src *App, event string, data interface{}) {
+	s.publishWS(src, event, data)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch, app := range s.subs {
+		if app != src {
+			continue
+		}
+		select {
+		case ch <- apiEvent{Event: event, Data: data}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sessionBackupMagic prefixes encrypted backup files; a backup written
+// without an available key (see sessioncrypt.go) is plain JSON and is
+// recognized by its missing magic, mirroring how sessions load.
+const sessionBackupMagic = "TTSB1"
+
+// sessionBackupKeep is how many backups are kept per account; older ones
+// are pruned when a new snapshot is written.
+const sessionBackupKeep = 10
+
+// sessionStamp is the timestamp layout in backup file names, shared with
+// the migration backups in migrate.go.
+const sessionStamp = "20060102-150405"
+
+// sessionSnapshot is the payload of one backup: every file in the
+// account's session directory at the time of the snapshot.
+type sessionSnapshot struct {
+	Account string            `json:"account"`
+	Time    time.Time         `json:"time"`
+	Files   map[string][]byte `json:"files"`
+}
+
+// SessionBackup is the UI-facing description of one stored backup.
+type SessionBackup struct {
+	ID        string    `json:"id"`
+	Account   string    `json:"account"`
+	Time      time.Time `json:"time"`
+	Encrypted bool      `json:"encrypted"`
+}
+
+// backupSessionDir snapshots <baseDir>/accounts/<name> into a single
+// sealed file under backups/sessions and prunes old snapshots, returning
+// the backup's path. The snapshot is encrypted with the same key that
+// protects the session itself; without one it falls back to plaintext
+// rather than skipping the backup. An account with no session files yields
+// "" and no error.
+func backupSessionDir(baseDir, name string) (string, error) {
+	accountDir := filepath.Join(baseDir, "accounts", name)
+	entries, err := os.ReadDir(accountDir)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	snap := sessionSnapshot{Account: name, Time: time.Now(), Files: make(map[string][]byte)}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(accountDir, entry.Name()))
+		if err != nil {
+			return "", err
+		}
+		snap.Files[entry.Name()] = data
+	}
+	if len(snap.Files) == 0 {
+		return "", nil
+	}
+	payload, err := json.Marshal(snap)
+	if err != nil {
+		return "", err
+	}
+	if key, err := sessionKey(filepath.Join(accountDir, "session.json")); err == nil {
+		sealed, err := (&encryptedSessionStorage{key: key}).encrypt(payload)
+		if err != nil {
+			return "", err
+		}
+		payload = append([]byte(sessionBackupMagic), sealed...)
+	} else {
+		logWarnf("session: no encryption key (%v); storing backup in plaintext", err)
+	}
+
+	dir := filepath.Join(baseDir, "backups", "sessions")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.ttsb", name, snap.Time.Format(sessionStamp)))
+	if _, err := os.Stat(path); err == nil {
+		// A snapshot from this second already exists (ResetSession backs up
+		// and then funnels into RemoveAccount, which backs up again); keep
+		// the earlier, more complete one.
+		return path, nil
+	}
+	if err := os.WriteFile(path, payload, 0o600); err != nil {
+		return "", err
+	}
+	// With the passphrase-derived key the scrypt salt lives next to the
+	// session, which removal deletes — keep a copy beside the backup or a
+	// later restore could never derive the key again. A salt is not secret,
+	// so it is stored in the clear.
+	if salt, err := os.ReadFile(filepath.Join(accountDir, "session.json.salt")); err == nil {
+		if err := os.WriteFile(path+".salt", salt, 0o600); err != nil {
+			return "", err
+		}
+	}
+	pruneSessionBackups(dir, name)
+	return path, nil
+}
+
+// pruneSessionBackups removes the oldest backups of one account beyond
+// sessionBackupKeep. The timestamp in the name sorts lexicographically.
+func pruneSessionBackups(dir, name string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var mine []string
+	for _, entry := range entries {
+		if account, _, ok := parseSessionBackupID(entry.Name()); ok && account == name {
+			mine = append(mine, entry.Name())
+		}
+	}
+	sort.Strings(mine)
+	for len(mine) > sessionBackupKeep {
+		os.Remove(filepath.Join(dir, mine[0]))
+		os.Remove(filepath.Join(dir, mine[0]+".salt"))
+		mine = mine[1:]
+	}
+}
+
+// parseSessionBackupID splits "<account>-<stamp>.ttsb" around the
+// fixed-width timestamp, so account names containing dashes survive.
+func parseSessionBackupID(id string) (account string, at time.Time, ok bool) {
+	base, found := strings.CutSuffix(id, ".ttsb")
+	if !found || len(base) < len(sessionStamp)+2 || base[len(base)-len(sessionStamp)-1] != '-' {
+		return "", time.Time{}, false
+	}
+	account = base[:len(base)-len(sessionStamp)-1]
+	at, err := time.ParseInLocation(sessionStamp, base[len(base)-len(sessionStamp):], time.Local)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return account, at, true
+}
+
+// backupSession snapshots an account's session before an operation that
+// could destroy it. Failures are logged but never block the operation —
+// the backup is a safety net, not a gate.
+func (a *App) backupSession(name string) {
+	path, err := backupSessionDir(a.baseDir, name)
+	if err != nil {
+		logWarnf("session: backup of %s failed: %v", name, err)
+		return
+	}
+	if path == "" {
+		return
+	}
+	logf("session: backed up %s to %s", name, path)
+	a.audit.Record("session:backup", name)
+}
+
+// ListSessionBackups returns the stored session backups, newest first.
+func (a *App) ListSessionBackups() []SessionBackup {
+	dir := filepath.Join(a.baseDir, "backups", "sessions")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return []SessionBackup{}
+	}
+	backups := make([]SessionBackup, 0, len(entries))
+	for _, entry := range entries {
+		account, at, ok := parseSessionBackupID(entry.Name())
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, SessionBackup{
+			ID:        entry.Name(),
+			Account:   account,
+			Time:      at,
+			Encrypted: len(data) >= len(sessionBackupMagic) && string(data[:len(sessionBackupMagic)]) == sessionBackupMagic,
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Time.After(backups[j].Time) })
+	return backups
+}
+
+// RestoreSessionBackup puts a backup's files back into the account's
+// session directory. The account must not be loaded: restore after a
+// regretted RemoveAccount or ResetSession, then add and connect the
+// account again.
+func (a *App) RestoreSessionBackup(id string) Response {
+	if filepath.Base(id) != id {
+		return respErr(CodeInvalidArgument, "malformed backup id")
+	}
+	account, _, ok := parseSessionBackupID(id)
+	if !ok {
+		return respErr(CodeInvalidArgument, "malformed backup id")
+	}
+	a.mu.Lock()
+	_, loaded := a.bundles[account]
+	a.mu.Unlock()
+	if loaded {
+		return respErr(CodeConflict, "account "+account+" is loaded; remove it before restoring")
+	}
+
+	backupPath := filepath.Join(a.baseDir, "backups", "sessions", id)
+	data, err := os.ReadFile(backupPath)
+	if err != nil {
+		return respError(err)
+	}
+	accountDir := filepath.Join(a.baseDir, "accounts", account)
+	if len(data) >= len(sessionBackupMagic) && string(data[:len(sessionBackupMagic)]) == sessionBackupMagic {
+		// Put the backed-up scrypt salt back first, or a passphrase-derived
+		// key would be built from a freshly generated salt and never match.
+		if salt, err := os.ReadFile(backupPath + ".salt"); err == nil {
+			saltPath := filepath.Join(accountDir, "session.json.salt")
+			if _, err := os.Stat(saltPath); os.IsNotExist(err) {
+				if err := os.MkdirAll(accountDir, 0o755); err != nil {
+					return respError(err)
+				}
+				if err := os.WriteFile(saltPath, salt, 0o600); err != nil {
+					return respError(err)
+				}
+			}
+		}
+		key, err := sessionKey(filepath.Join(accountDir, "session.json"))
+		if err != nil {
+			return respError(fmt.Errorf("backup is encrypted but no key is available: %w", err))
+		}
+		if data, err = (&encryptedSessionStorage{key: key}).decrypt(data[len(sessionBackupMagic):]); err != nil {
+			return respError(err)
+		}
+	}
+	var snap sessionSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return respError(fmt.Errorf("corrupt backup: %w", err))
+	}
+	if err := os.MkdirAll(accountDir, 0o755); err != nil {
+		return respError(err)
+	}
+	for name, contents := range snap.Files {
+		if filepath.Base(name) != name {
+			continue // never write outside the account dir
+		}
+		if err := os.WriteFile(filepath.Join(accountDir, name), contents, 0o600); err != nil {
+			return respError(err)
+		}
+	}
+	a.audit.Record("session:restore", account)
+	return respOK()
+}
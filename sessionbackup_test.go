@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSessionBackupID(t *testing.T) {
+	cases := []struct {
+		id      string
+		account string
+		at      time.Time
+	}{
+		{
+			id:      "default-20260901-143000.ttsb",
+			account: "default",
+			at:      time.Date(2026, 9, 1, 14, 30, 0, 0, time.Local),
+		},
+		{
+			// Account names containing dashes must survive the split.
+			id:      "work-account-2-20260901-143000.ttsb",
+			account: "work-account-2",
+			at:      time.Date(2026, 9, 1, 14, 30, 0, 0, time.Local),
+		},
+	}
+	for _, tc := range cases {
+		account, at, ok := parseSessionBackupID(tc.id)
+		if !ok {
+			t.Errorf("parseSessionBackupID(%q) not ok", tc.id)
+			continue
+		}
+		if account != tc.account || !at.Equal(tc.at) {
+			t.Errorf("parseSessionBackupID(%q) = %q, %v; want %q, %v", tc.id, account, at, tc.account, tc.at)
+		}
+	}
+}
+
+func TestParseSessionBackupIDRejectsMalformed(t *testing.T) {
+	for _, id := range []string{
+		"",
+		"default-20260901-143000",           // wrong extension
+		"default-20260901-143000.ttsb.salt", // salt sidecar, not a backup
+		"20260901-143000.ttsb",              // no account name
+		"default-2026090x-143000.ttsb",      // unparseable stamp
+		"default.ttsb",                      // no stamp at all
+	} {
+		if account, _, ok := parseSessionBackupID(id); ok {
+			t.Errorf("parseSessionBackupID(%q) = %q, ok; want rejection", id, account)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/gotd/td/session"
+	"github.com/gotd/td/telegram"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/scrypt"
+)
+
+// sessionMagic prefixes encrypted session files so a legacy plaintext
+// session.json is still recognized and migrated on the next store.
+const sessionMagic = "TTSE1"
+
+// keyringService and keyringUser identify the session key in the OS
+// keychain (Keychain on macOS, Credential Manager/DPAPI on Windows,
+// Secret Service on Linux).
+const (
+	keyringService = "TeleTurbo"
+	keyringUser    = "session-key"
+)
+
+// newSessionStorage returns storage that encrypts the session at rest.
+// The key comes from the OS keychain; on systems without one (headless
+// Linux without a secret service) the TELETURBO_SESSION_PASSPHRASE
+// environment variable is used instead. With neither available the
+// session falls back to plaintext, with a warning, rather than locking
+// the user out.
+func newSessionStorage(path string) telegram.SessionStorage {
+	key, err := sessionKey(path)
+	if err != nil {
+		logWarnf("session: no encryption key (%v); storing session in plaintext", err)
+		return &session.FileStorage{Path: path}
+	}
+	return &encryptedSessionStorage{path: path, key: key}
+}
+
+// sessionKey fetches (or creates) the 32-byte session key, preferring the
+// OS keychain and falling back to a passphrase-derived key.
+func sessionKey(path string) ([]byte, error) {
+	stored, err := keyring.Get(keyringService, keyringUser)
+	if err == nil {
+		key, err := base64.StdEncoding.DecodeString(stored)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("keychain holds a malformed session key")
+		}
+		return key, nil
+	}
+	if errors.Is(err, keyring.ErrNotFound) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := keyring.Set(keyringService, keyringUser, base64.StdEncoding.EncodeToString(key)); err != nil {
+			return passphraseKey(path)
+		}
+		return key, nil
+	}
+	// No usable secret service; try the passphrase fallback.
+	return passphraseKey(path)
+}
+
+// passphraseKey derives the session key from TELETURBO_SESSION_PASSPHRASE
+// with scrypt, using a random per-install salt stored next to the session.
+func passphraseKey(path string) ([]byte, error) {
+	pass := os.Getenv("TELETURBO_SESSION_PASSPHRASE")
+	if pass == "" {
+		return nil, fmt.Errorf("no OS keychain and TELETURBO_SESSION_PASSPHRASE is not set")
+	}
+	saltPath := path + ".salt"
+	salt, err := os.ReadFile(saltPath)
+	if os.IsNotExist(err) {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(saltPath, salt, 0o600); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return scrypt.Key([]byte(pass), salt, 1<<15, 8, 1, 32)
+}
+
+// encryptedSessionStorage is a telegram.SessionStorage that holds the
+// session AES-256-GCM encrypted on disk. A plaintext legacy file loads
+// as-is and is encrypted on the next store.
+type encryptedSessionStorage struct {
+	path string
+	key  []byte
+}
+
+func (s *encryptedSessionStorage) LoadSession(ctx context.Context) ([]byte, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, session.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < len(sessionMagic) || string(data[:len(sessionMagic)]) != sessionMagic {
+		// Legacy plaintext session from before encryption landed.
+		return data, nil
+	}
+	return s.decrypt(data[len(sessionMagic):])
+}
+
+func (s *encryptedSessionStorage) StoreSession(ctx context.Context, data []byte) error {
+	sealed, err := s.encrypt(data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, append([]byte(sessionMagic), sealed...), 0o600)
+}
+
+func (s *encryptedSessionStorage) encrypt(plain []byte) ([]byte, error) {
+	aead, err := s.aead()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func (s *encryptedSessionStorage) decrypt(sealed []byte) ([]byte, error) {
+	aead, err := s.aead()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("session file is truncated")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt session (wrong key or corrupt file): %w", err)
+	}
+	return plain, nil
+}
+
+func (s *encryptedSessionStorage) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ActiveSession describes one device holding an authorization for the
+// account, as shown in the sessions review UI.
+type ActiveSession struct {
+	Hash          int64     `json:"hash"` // 0 for the current session
+	Current       bool      `json:"current"`
+	DeviceModel   string    `json:"deviceModel"`
+	Platform      string    `json:"platform"`
+	SystemVersion string    `json:"systemVersion"`
+	AppName       string    `json:"appName"`
+	AppVersion    string    `json:"appVersion"`
+	Official      bool      `json:"official"`
+	Created       time.Time `json:"created"`
+	LastActive    time.Time `json:"lastActive"`
+	IP            string    `json:"ip"`
+	Country       string    `json:"country"`
+}
+
+// ActiveSessions lists every device with a live session for the account.
+func (c *TGClient) ActiveSessions(ctx context.Context) ([]ActiveSession, error) {
+	res, err := c.API().AccountGetAuthorizations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get authorizations: %w", err)
+	}
+	sessions := make([]ActiveSession, 0, len(res.Authorizations))
+	for _, auth := range res.Authorizations {
+		sessions = append(sessions, ActiveSession{
+			Hash:          auth.Hash,
+			Current:       auth.Current,
+			DeviceModel:   auth.DeviceModel,
+			Platform:      auth.Platform,
+			SystemVersion: auth.SystemVersion,
+			AppName:       auth.AppName,
+			AppVersion:    auth.AppVersion,
+			Official:      auth.OfficialApp,
+			Created:       time.Unix(int64(auth.DateCreated), 0),
+			LastActive:    time.Unix(int64(auth.DateActive), 0),
+			IP:            auth.IP,
+			Country:       auth.Country,
+		})
+	}
+	return sessions, nil
+}
+
+// TerminateSession kills the session identified by its authorization hash.
+func (c *TGClient) TerminateSession(ctx context.Context, hash int64) error {
+	if _, err := c.API().AccountResetAuthorization(ctx, hash); err != nil {
+		return fmt.Errorf("reset authorization: %w", err)
+	}
+	return nil
+}
+
+// TerminateOtherSessions kills every session except the current one.
+func (c *TGClient) TerminateOtherSessions(ctx context.Context) error {
+	if _, err := c.API().AuthResetAuthorizations(ctx); err != nil {
+		return fmt.Errorf("reset authorizations: %w", err)
+	}
+	return nil
+}
+
+// GetActiveSessions lists the devices holding sessions for the active
+// account, so suspicious ones can be reviewed and killed from TeleTurbo.
+func (a *App) GetActiveSessions() []ActiveSession {
+	b := a.active()
+	if b == nil {
+		return nil
+	}
+	sessions, err := b.client.ActiveSessions(a.ctx)
+	if err != nil {
+		a.emit("sessions:error", err.Error())
+		return nil
+	}
+	return sessions
+}
+
+// TerminateSession kills one other device's session by its hash from
+// GetActiveSessions.
+func (a *App) TerminateSession(hash int64) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	if hash == 0 {
+		return respErr(CodeInvalidArgument, "cannot terminate the current session; use RemoveAccount")
+	}
+	if err := b.client.TerminateSession(a.ctx, hash); err != nil {
+		return respError(err)
+	}
+	return respOK()
+}
+
+// TerminateAllOtherSessions kills every session except this one.
+func (a *App) TerminateAllOtherSessions() Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	if err := b.client.TerminateOtherSessions(a.ctx); err != nil {
+		return respError(err)
+	}
+	return respOK()
+}
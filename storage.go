@@ -0,0 +1,167 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go.etcd.io/bbolt"
+	_ "modernc.org/sqlite"
+)
+
+// stateStore abstracts where per-profile state documents — the task list,
+// transfer history, jobs, the bandwidth schedule — are kept. Documents
+// are whole JSON blobs keyed by their traditional file name, so the
+// default backend writes exactly the files previous releases wrote and
+// switching backends never changes what is stored, only where.
+type stateStore interface {
+	// Load returns a document's bytes, or nil without error when the
+	// document does not exist yet.
+	Load(name string) ([]byte, error)
+	Store(name string, data []byte) error
+}
+
+// Storage backends for -state-backend / TELETURBO_STATE_BACKEND. The
+// single-file backends avoid the many-small-files pattern that some NAS
+// filesystems handle badly; both are pure Go, so no platform needs cgo.
+const (
+	backendJSON   = "json"   // one JSON file per document (the default)
+	backendBolt   = "bolt"   // all documents in one bbolt state.db
+	backendSQLite = "sqlite" // all documents in one SQLite state.sqlite
+)
+
+// stateStores shares one open store per state directory: the single-file
+// backends hold an exclusive lock, so the task list, history and jobs of
+// one profile must go through the same handle.
+var stateStores = struct {
+	mu sync.Mutex
+	m  map[string]stateStore
+}{m: make(map[string]stateStore)}
+
+// stateStoreFor returns the configured store for a state directory,
+// opening it on first use. A backend that fails to open degrades to the
+// JSON files backend rather than losing state writes.
+func stateStoreFor(dir string) stateStore {
+	stateStores.mu.Lock()
+	defer stateStores.mu.Unlock()
+	if s, ok := stateStores.m[dir]; ok {
+		return s
+	}
+	var s stateStore
+	var err error
+	switch overrides.StateBackend {
+	case backendBolt:
+		s, err = openBoltStore(dir)
+	case backendSQLite:
+		s, err = openSQLiteStore(dir)
+	}
+	if err != nil {
+		logWarnf("storage: %s backend: %v (falling back to json files)", overrides.StateBackend, err)
+	}
+	if s == nil {
+		s = jsonFileStore{dir: dir}
+	}
+	stateStores.m[dir] = s
+	return s
+}
+
+// jsonFileStore is the traditional backend: one pretty-printed JSON file
+// per document, directly inspectable and diffable.
+type jsonFileStore struct {
+	dir string
+}
+
+func (s jsonFileStore) Load(name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s jsonFileStore) Store(name string, data []byte) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, name), data, 0o600)
+}
+
+// boltStore keeps every document in one bbolt file.
+type boltStore struct {
+	db *bbolt.DB
+}
+
+var boltBucket = []byte("state")
+
+func openBoltStore(dir string) (stateStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	db, err := bbolt.Open(filepath.Join(dir, "state.db"), 0o600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return boltStore{db: db}, nil
+}
+
+func (s boltStore) Load(name string) ([]byte, error) {
+	var data []byte
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		if v := tx.Bucket(boltBucket).Get([]byte(name)); v != nil {
+			data = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return data, err
+}
+
+func (s boltStore) Store(name string, data []byte) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(name), data)
+	})
+}
+
+// sqliteStore keeps every document in one SQLite file, via the cgo-free
+// driver.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func openSQLiteStore(dir string) (stateStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", filepath.Join(dir, "state.sqlite"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS state (name TEXT PRIMARY KEY, data BLOB NOT NULL)`); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return sqliteStore{db: db}, nil
+}
+
+func (s sqliteStore) Load(name string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM state WHERE name = ?`, name).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s sqliteStore) Store(name string, data []byte) error {
+	_, err := s.db.Exec(`INSERT INTO state (name, data) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET data = excluded.data`, name, data)
+	return err
+}
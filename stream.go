@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gotd/td/tg"
+)
+
+// streamPartSize is the fixed range fetched per upload.getFile call while
+// streaming. Telegram requires part-aligned offsets, so requested byte
+// ranges are aligned down and the surplus head is discarded.
+const streamPartSize = 512 * 1024
+
+// streamSource is one registered playable file: the link plus the account
+// bundle whose connection serves it. The media is re-resolved per request,
+// so streaming works before, during and after a download of the same
+// message.
+type streamSource struct {
+	bundle *accountBundle
+	link   *LinkInfo
+}
+
+// GetStreamURL registers a message link for local playback and returns a
+// URL on the embedded streaming server, e.g. for "Play in VLC". The
+// server binds to a loopback port on first use and serves byte ranges by
+// fetching them from Telegram on demand, so playback and seeking work
+// without waiting for a download to finish.
+func (a *App) GetStreamURL(link string) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	info, err := ParseLink(link)
+	if err != nil {
+		return respError(err)
+	}
+	if info.InviteHash != "" {
+		return respErr(CodeLinkInvalid, "invite link; join the channel first via JoinChannel")
+	}
+	if err := a.ensureStreamServer(); err != nil {
+		return respError(err)
+	}
+	a.mu.Lock()
+	a.nextStreamID++
+	id := fmt.Sprintf("stream-%d", a.nextStreamID)
+	a.streams[id] = &streamSource{bundle: b, link: info}
+	addr := a.streamLn.Addr().String()
+	a.mu.Unlock()
+	a.audit.Record("stream", id+" "+link)
+	return respID(fmt.Sprintf("http://%s/stream/%s", addr, id))
+}
+
+// ensureStreamServer starts the loopback streaming server on first use.
+// Binding to 127.0.0.1 keeps it reachable by local players only; the
+// random port avoids colliding with anything the user runs.
+func (a *App) ensureStreamServer() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.streamLn != nil {
+		return nil
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("stream server: %w", err)
+	}
+	a.streamLn = ln
+	a.streams = make(map[string]*streamSource)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream/", a.handleStream)
+	go http.Serve(ln, mux)
+	logf("stream server listening on %s", ln.Addr())
+	return nil
+}
+
+// handleStream serves one registered source with byte-range support, the
+// contract media players rely on for seeking.
+func (a *App) handleStream(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/stream/")
+	a.mu.Lock()
+	src := a.streams[id]
+	a.mu.Unlock()
+	if src == nil {
+		http.NotFound(w, r)
+		return
+	}
+	ctx := r.Context()
+
+	// The throwaway task carries the link into the downloader's resolve
+	// and chunk-fetch machinery; nothing about it is registered or saved.
+	task := &DownloadTask{ID: id, Link: src.link}
+	dl := src.bundle.downloader
+	loc, size, name, err := dl.prepare(ctx, task)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	ref := &fileRef{loc: loc, refresh: func(ctx context.Context) (tg.InputFileLocationClass, error) {
+		loc, _, _, err := dl.prepare(ctx, task)
+		return loc, err
+	}}
+
+	start, end, ok := parseByteRange(r.Header.Get("Range"), size)
+	if !ok {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		http.Error(w, "unsatisfiable range", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	ctype := mime.TypeByExtension(filepath.Ext(name))
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+	if r.Header.Get("Range") != "" {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+		w.WriteHeader(http.StatusPartialContent)
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	off := start - start%streamPartSize
+	skip := start - off
+	remaining := end - start + 1
+	for remaining > 0 {
+		part, err := dl.fetchChunk(ctx, task, ref, off, streamPartSize)
+		if err != nil {
+			return // headers are sent; all we can do is drop the connection
+		}
+		b := part.Bytes
+		if skip > 0 {
+			if skip >= int64(len(b)) {
+				return
+			}
+			b = b[skip:]
+			skip = 0
+		}
+		if int64(len(b)) > remaining {
+			b = b[:remaining]
+		}
+		if _, err := w.Write(b); err != nil {
+			return // player seeked or closed; it will reconnect with a new range
+		}
+		remaining -= int64(len(b))
+		if len(part.Bytes) < streamPartSize {
+			return
+		}
+		off += streamPartSize
+	}
+}
+
+// parseByteRange decodes a single-range "bytes=start-end" header against
+// the file size. An absent header means the whole file; multi-range
+// requests, which players don't send, take the first range.
+func parseByteRange(header string, size int64) (start, end int64, ok bool) {
+	if size <= 0 {
+		return 0, 0, false
+	}
+	if header == "" {
+		return 0, size - 1, true
+	}
+	spec := strings.TrimPrefix(header, "bytes=")
+	if spec == header {
+		return 0, 0, false
+	}
+	if i := strings.IndexByte(spec, ','); i >= 0 {
+		spec = spec[:i]
+	}
+	from, to, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false
+	}
+	if from == "" {
+		// Suffix form "-N": the last N bytes.
+		n, err := strconv.ParseInt(to, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	}
+	start, err := strconv.ParseInt(from, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+	end = size - 1
+	if to != "" {
+		if end, err = strconv.ParseInt(to, 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, true
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestParseByteRange(t *testing.T) {
+	cases := []struct {
+		name       string
+		header     string
+		size       int64
+		start, end int64
+		ok         bool
+	}{
+		{name: "no header means whole file", header: "", size: 100, start: 0, end: 99, ok: true},
+		{name: "open ended", header: "bytes=10-", size: 100, start: 10, end: 99, ok: true},
+		{name: "closed range", header: "bytes=10-19", size: 100, start: 10, end: 19, ok: true},
+		{name: "end clamped to size", header: "bytes=10-500", size: 100, start: 10, end: 99, ok: true},
+		{name: "suffix form", header: "bytes=-25", size: 100, start: 75, end: 99, ok: true},
+		{name: "suffix longer than file", header: "bytes=-500", size: 100, start: 0, end: 99, ok: true},
+		{name: "multi-range takes first", header: "bytes=0-9,20-29", size: 100, start: 0, end: 9, ok: true},
+		{name: "zero size", header: "", size: 0, ok: false},
+		{name: "missing unit", header: "10-19", size: 100, ok: false},
+		{name: "start past end of file", header: "bytes=100-", size: 100, ok: false},
+		{name: "end before start", header: "bytes=20-10", size: 100, ok: false},
+		{name: "negative start", header: "bytes=-5-10", size: 100, ok: false},
+		{name: "no dash", header: "bytes=10", size: 100, ok: false},
+		{name: "empty suffix", header: "bytes=-", size: 100, ok: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end, ok := parseByteRange(tc.header, tc.size)
+			if ok != tc.ok {
+				t.Fatalf("parseByteRange(%q, %d) ok = %v, want %v", tc.header, tc.size, ok, tc.ok)
+			}
+			if ok && (start != tc.start || end != tc.end) {
+				t.Errorf("parseByteRange(%q, %d) = %d-%d, want %d-%d", tc.header, tc.size, start, end, tc.start, tc.end)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// takeoutFileMaxSize is the largest file the takeout session asks Telegram
+// to include.
+const takeoutFileMaxSize = 4000 * 1024 * 1024
+
+// TakeoutState is the persisted progress of the data-export consent flow.
+// Telegram may answer account.initTakeoutSession with TAKEOUT_INIT_DELAY
+// (typically 24h) and ask the user to confirm through an official client;
+// the pending request is recorded so it can be resumed after the delay.
+type TakeoutState struct {
+	SessionID   int64     `json:"sessionId"` // 0 until the session is granted
+	Pending     bool      `json:"pending"`
+	RequestedAt time.Time `json:"requestedAt"`
+	RetryAt     time.Time `json:"retryAt"`
+}
+
+func (c *TGClient) takeoutStatePath() string {
+	return filepath.Join(c.StateDir(), "takeout.json")
+}
+
+// TakeoutStatus returns the stored takeout state without touching the API.
+func (c *TGClient) TakeoutStatus() TakeoutState {
+	var st TakeoutState
+	data, err := os.ReadFile(c.takeoutStatePath())
+	if err != nil {
+		return st
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		logErrorf("takeout: load: %v", err)
+	}
+	return st
+}
+
+func (c *TGClient) saveTakeoutState(st TakeoutState) {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(c.takeoutStatePath(), data, 0o600); err != nil {
+		logErrorf("takeout: save: %v", err)
+	}
+}
+
+// InitTakeout requests a takeout (data export) session. When Telegram
+// defers the request for user confirmation, the pending state is persisted
+// and returned instead of an error; call ResumeTakeout after the delay.
+func (c *TGClient) InitTakeout(ctx context.Context) (TakeoutState, error) {
+	st := c.TakeoutStatus()
+	if st.SessionID != 0 {
+		return st, nil
+	}
+	res, err := c.api.AccountInitTakeoutSession(ctx, &tg.AccountInitTakeoutSessionRequest{
+		Files:       true,
+		FileMaxSize: takeoutFileMaxSize,
+	})
+	if err != nil {
+		if rpc, ok := tgerr.As(err); ok && rpc.Type == "TAKEOUT_INIT_DELAY" {
+			now := time.Now()
+			st = TakeoutState{
+				Pending:     true,
+				RequestedAt: now,
+				RetryAt:     now.Add(time.Duration(rpc.Argument) * time.Second),
+			}
+			c.saveTakeoutState(st)
+			return st, nil
+		}
+		return st, fmt.Errorf("init takeout: %w", err)
+	}
+	st = TakeoutState{SessionID: res.ID, RequestedAt: time.Now()}
+	c.saveTakeoutState(st)
+	return st, nil
+}
+
+// invokeWithTakeoutID is the TL constructor ID of invokeWithTakeout,
+// which binds a query to a granted takeout session.
+const invokeWithTakeoutID = 0xaca9fd2e
+
+// takeoutQuery encodes one request wrapped in invokeWithTakeout.
+type takeoutQuery struct {
+	id    int64
+	query bin.Encoder
+}
+
+func (q takeoutQuery) Encode(b *bin.Buffer) error {
+	b.PutID(invokeWithTakeoutID)
+	b.PutLong(q.id)
+	return q.query.Encode(b)
+}
+
+// takeoutInvoker wraps every raw call in invokeWithTakeout. Telegram
+// rate-limits takeout traffic far more generously than regular calls,
+// which is what makes whole-channel scrapes viable.
+type takeoutInvoker struct {
+	next tg.Invoker
+	id   int64
+}
+
+func (t takeoutInvoker) Invoke(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+	return t.next.Invoke(ctx, takeoutQuery{id: t.id, query: input}, output)
+}
+
+// TakeoutAPI returns a raw client whose calls run inside the granted
+// takeout session, or nil when none was granted yet; see InitTakeout for
+// the consent flow.
+func (c *TGClient) TakeoutAPI() *tg.Client {
+	st := c.TakeoutStatus()
+	if st.SessionID == 0 {
+		return nil
+	}
+	return tg.NewClient(takeoutInvoker{next: c.client, id: st.SessionID})
+}
+
+// ResumeTakeout retries a pending takeout request once its delay has
+// passed, typically on startup. It is a no-op when nothing is pending or
+// the delay is still running.
+func (c *TGClient) ResumeTakeout(ctx context.Context) (TakeoutState, error) {
+	st := c.TakeoutStatus()
+	if !st.Pending || time.Now().Before(st.RetryAt) {
+		return st, nil
+	}
+	// Re-init: after the user approved, the same call now succeeds.
+	c.saveTakeoutState(TakeoutState{})
+	return c.InitTakeout(ctx)
+}
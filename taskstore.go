@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tasksName is the state document holding the task list; dir is the
+// state directory, usually the config dir or a profile's subdirectory.
+const tasksName = "tasks.json"
+
+// loadTasks reads the persisted task list. Tasks that were queued or
+// running when the app last exited come back paused, so the user can
+// resume or discard them.
+func loadTasks(dir string) ([]*DownloadTask, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("no state directory")
+	}
+	data, err := stateStoreFor(dir).Load(tasksName)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var tasks []*DownloadTask
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		if task.Status == "queued" || task.Status == "running" {
+			task.Status = "paused"
+		}
+		task.limit = newRateLimiter(task.LimitBytesPerSec)
+	}
+	return tasks, nil
+}
+
+// saveTasks persists the task list, sorted by task number so the file
+// diffs cleanly.
+func saveTasks(dir string, tasks map[string]*DownloadTask) {
+	if dir == "" {
+		return
+	}
+	list := make([]*DownloadTask, 0, len(tasks))
+	for _, task := range tasks {
+		list = append(list, task)
+	}
+	sort.Slice(list, func(i, j int) bool { return taskNumber(list[i].ID) < taskNumber(list[j].ID) })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := stateStoreFor(dir).Store(tasksName, data); err != nil {
+		logErrorf("tasks: save: %v", err)
+	}
+}
+
+func taskNumber(id string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(id, "task-"))
+	return n
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// Template variables usable in a filename template. A template may contain
+// path separators to sort files into subdirectories, e.g.
+// "{channel}/{date}_{msg_id}_{filename}".
+var templateVars = []string{
+	"{channel}",  // channel title, falling back to the username
+	"{username}", // channel username, may be empty for private channels
+	"{msg_id}",   // message ID
+	"{date}",     // message date as YYYY-MM-DD
+	"{sender}",   // post author when signed, else empty
+	"{type}",     // photo, video, audio or document
+	"{filename}", // the raw filename the media would otherwise get
+}
+
+// validateTemplate rejects templates with unknown variables so typos fail
+// at configuration time instead of producing odd filenames later.
+func validateTemplate(tpl string) error {
+	rest := tpl
+	for {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			return nil
+		}
+		closing := strings.IndexByte(rest[open:], '}')
+		if closing < 0 {
+			return fmt.Errorf("unclosed { in template")
+		}
+		v := rest[open : open+closing+1]
+		known := false
+		for _, k := range templateVars {
+			if v == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown template variable %s", v)
+		}
+		rest = rest[open+closing+1:]
+	}
+}
+
+// PreviewNamingTemplate renders a template against a real sample message
+// and returns the exact path it would produce, relative to the
+// destination directory, so a template can be proofed before a 10k-file
+// export runs with it. Unknown variables are rejected up front, like
+// SetFilenameTemplate does.
+func (a *App) PreviewNamingTemplate(template, sampleLink string) Response {
+	if err := validateTemplate(template); err != nil {
+		return respErr(CodeInvalidArgument, err.Error())
+	}
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	info, err := ParseLink(sampleLink)
+	if err != nil {
+		return respError(err)
+	}
+	if info.InviteHash != "" {
+		return respErr(CodeLinkInvalid, "invite link; join the channel first via JoinChannel")
+	}
+	// A throwaway task carries the template through the same resolve and
+	// render path a real download takes, so the preview is exact.
+	task := &DownloadTask{Link: info, NameTemplate: template}
+	_, _, name, err := b.downloader.prepare(a.ctx, task)
+	if err != nil {
+		return respError(err)
+	}
+	return respID(name)
+}
+
+// renderName applies the task's filename template to a message, returning
+// a path relative to the task's destination directory. An empty template
+// or a render that comes out empty falls back to the raw filename.
+func (d *Downloader) renderName(task *DownloadTask, msg *tg.Message, rawName string) string {
+	tpl := task.NameTemplate
+	if tpl == "" {
+		return rawName
+	}
+	channel := d.client.ChannelTitle(task.channelID)
+	if channel == "" {
+		channel = task.Link.Username
+	}
+	sender, _ := msg.GetPostAuthor()
+	repl := strings.NewReplacer(
+		"{channel}", sanitizeComponent(channel),
+		"{username}", sanitizeComponent(task.Link.Username),
+		"{msg_id}", fmt.Sprintf("%d", msg.ID),
+		"{date}", time.Unix(int64(msg.Date), 0).Format("2006-01-02"),
+		"{sender}", sanitizeComponent(sender),
+		"{type}", mediaKind(msg),
+		"{filename}", sanitizeComponent(rawName),
+	)
+	out := repl.Replace(tpl)
+
+	// Normalize the path: no absolute paths, no empty or dot-dot segments.
+	parts := strings.FieldsFunc(out, func(r rune) bool { return r == '/' || r == '\\' })
+	clean := parts[:0]
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" || p == "." || p == ".." {
+			continue
+		}
+		clean = append(clean, p)
+	}
+	if len(clean) == 0 {
+		return rawName
+	}
+	return strings.Join(clean, "/")
+}
+
+// sanitizeComponent strips characters that are path separators or invalid
+// in filenames on common filesystems.
+func sanitizeComponent(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, s)
+}
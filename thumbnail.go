@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/gotd/td/tg"
+)
+
+// thumbFetchLimit bounds one thumbnail fetch; thumbs are a few dozen KB,
+// so a single upload.getFile call always covers them.
+const thumbFetchLimit = 512 * 1024
+
+// thumbLocation picks the smallest preview of a message's media: the
+// smallest PhotoSize for photos, the smallest thumb for documents and
+// videos. Returns an error for media without any preview.
+func thumbLocation(msg *tg.Message) (tg.InputFileLocationClass, error) {
+	media, ok := msg.GetMedia()
+	if !ok {
+		return nil, fmt.Errorf("message %d has no media", msg.ID)
+	}
+	switch m := media.(type) {
+	case *tg.MessageMediaPhoto:
+		photo, ok := m.Photo.AsNotEmpty()
+		if !ok {
+			return nil, fmt.Errorf("message %d: empty photo", msg.ID)
+		}
+		thumb, ok := smallestSizeType(photo.Sizes)
+		if !ok {
+			return nil, fmt.Errorf("message %d: photo has no sizes", msg.ID)
+		}
+		return &tg.InputPhotoFileLocation{
+			ID:            photo.ID,
+			AccessHash:    photo.AccessHash,
+			FileReference: photo.FileReference,
+			ThumbSize:     thumb,
+		}, nil
+	case *tg.MessageMediaDocument:
+		doc, ok := m.Document.AsNotEmpty()
+		if !ok {
+			return nil, fmt.Errorf("message %d: empty document", msg.ID)
+		}
+		thumb, ok := smallestSizeType(doc.Thumbs)
+		if !ok {
+			return nil, fmt.Errorf("message %d: document has no thumbnail", msg.ID)
+		}
+		return &tg.InputDocumentFileLocation{
+			ID:            doc.ID,
+			AccessHash:    doc.AccessHash,
+			FileReference: doc.FileReference,
+			ThumbSize:     thumb,
+		}, nil
+	default:
+		return nil, fmt.Errorf("message %d: unsupported media %T", msg.ID, media)
+	}
+}
+
+// smallestSizeType is the counterpart of largestPhotoSize: the type of
+// the smallest concrete size, for previews.
+func smallestSizeType(sizes []tg.PhotoSizeClass) (string, bool) {
+	var thumb string
+	var size int64
+	for _, s := range sizes {
+		if ps, ok := s.(*tg.PhotoSize); ok && (thumb == "" || int64(ps.Size) < size) {
+			thumb, size = ps.Type, int64(ps.Size)
+		}
+	}
+	return thumb, thumb != ""
+}
+
+// Thumbnail fetches a message's preview image and returns it as a data
+// URL the UI can drop into an <img> src directly.
+func (c *TGClient) Thumbnail(ctx context.Context, link *LinkInfo) (string, error) {
+	ch, err := c.ResolveChannel(ctx, link)
+	if err != nil {
+		return "", err
+	}
+	msg, err := c.GetMessage(ctx, ch, link.MessageID)
+	if err != nil {
+		return "", err
+	}
+	loc, err := thumbLocation(msg)
+	if err != nil {
+		return "", err
+	}
+	res, err := c.API().UploadGetFile(ctx, &tg.UploadGetFileRequest{
+		Location: loc,
+		Limit:    thumbFetchLimit,
+	})
+	if err != nil {
+		return "", fmt.Errorf("get thumbnail: %w", err)
+	}
+	file, ok := res.(*tg.UploadFile)
+	if !ok {
+		return "", fmt.Errorf("unexpected file type %T", res)
+	}
+	return "data:" + thumbMIME(file.Type) + ";base64," +
+		base64.StdEncoding.EncodeToString(file.Bytes), nil
+}
+
+// thumbMIME maps the storage file type to a MIME type; Telegram thumbs
+// are JPEG in practice, so that is also the fallback.
+func thumbMIME(t tg.StorageFileTypeClass) string {
+	switch t.(type) {
+	case *tg.StorageFilePng:
+		return "image/png"
+	case *tg.StorageFileGif:
+		return "image/gif"
+	case *tg.StorageFileWebp:
+		return "image/webp"
+	default:
+		return "image/jpeg"
+	}
+}
+
+// GetThumbnail returns a preview of the linked message's media as a
+// base64 data URL, for the media browser and download cards. Empty on
+// failure; the error rides a thumb:error event.
+func (a *App) GetThumbnail(link string) string {
+	b := a.active()
+	if b == nil {
+		return ""
+	}
+	info, err := ParseLink(link)
+	if err != nil {
+		a.emit("thumb:error", err.Error())
+		return ""
+	}
+	dataURL, err := b.client.Thumbnail(a.ctx, info)
+	if err != nil {
+		a.emit("thumb:error", err.Error())
+		return ""
+	}
+	return dataURL
+}
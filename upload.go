@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gotd/td/telegram/uploader"
+	"github.com/gotd/td/tg"
+)
+
+// UploadTask mirrors DownloadTask for the reverse direction: one local
+// file being sent to a chat. Written is updated atomically by the upload
+// goroutine and read by the UI bindings.
+type UploadTask struct {
+	ID       string `json:"id"`
+	Path     string `json:"path"`
+	Target   string `json:"target"` // chat link, or "" for Saved Messages
+	Caption  string `json:"caption"`
+	FileName string `json:"fileName"`
+	Size     int64  `json:"size"`
+	Written  int64  `json:"written"`
+	Status   string `json:"status"` // running, done, failed, cancelled
+	Error    string `json:"error"`
+
+	// mu guards Status and Error, which the upload goroutine writes while
+	// the bindings list and marshal the task; Written stays atomic.
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// MarshalJSON serializes the task under its mutex, so events and binding
+// results never observe a half-written state.
+func (t *UploadTask) MarshalJSON() ([]byte, error) {
+	type plain UploadTask
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return json.Marshal((*plain)(t))
+}
+
+func (t *UploadTask) setStatus(s string) {
+	t.mu.Lock()
+	t.Status = s
+	t.mu.Unlock()
+}
+
+func (t *UploadTask) status() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Status
+}
+
+func (t *UploadTask) setError(msg string) {
+	t.mu.Lock()
+	t.Error = msg
+	t.mu.Unlock()
+}
+
+// uploadThreads is the parallelism of chunked uploads, matching the
+// download default.
+const uploadThreads = 4
+
+// SendFile uploads a local file in parallel chunks and sends it to the
+// peer as a document message with the given caption. onChunk observes
+// progress as bytes uploaded so far.
+func (c *TGClient) SendFile(ctx context.Context, peer tg.InputPeerClass, path, caption string, onChunk func(uploaded, total int64)) error {
+	up := uploader.NewUploader(c.API()).WithThreads(uploadThreads).WithProgress(chunkObserver(onChunk))
+	file, err := up.FromPath(ctx, path)
+	if err != nil {
+		return fmt.Errorf("upload %s: %w", path, err)
+	}
+	_, err = c.API().MessagesSendMedia(ctx, &tg.MessagesSendMediaRequest{
+		Peer: peer,
+		Media: &tg.InputMediaUploadedDocument{
+			File:     file,
+			MimeType: "application/octet-stream",
+			Attributes: []tg.DocumentAttributeClass{
+				&tg.DocumentAttributeFilename{FileName: filepath.Base(path)},
+			},
+		},
+		Message:  caption,
+		RandomID: time.Now().UnixNano(),
+	})
+	if err != nil {
+		return fmt.Errorf("send media: %w", err)
+	}
+	return nil
+}
+
+// chunkObserver adapts a plain progress callback to gotd's uploader
+// progress interface.
+type chunkObserver func(uploaded, total int64)
+
+func (o chunkObserver) Chunk(_ context.Context, state uploader.ProgressState) error {
+	o(state.Uploaded, state.Total)
+	return nil
+}
+
+// StartUpload sends a local file to a chat on the active account;
+// chatTarget is any link ResolvePeer understands — a channel link, a
+// tg://openmessage chat or user link — or "" for Saved Messages. Returns
+// the upload task's ID; progress and the outcome arrive as upload:*
+// events.
+func (a *App) StartUpload(path, chatTarget, caption string) Response {
+	b := a.active()
+	if b == nil {
+		return respErr(CodeNotConnected, "no account connected")
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return respError(err)
+	}
+	var peer tg.InputPeerClass = &tg.InputPeerSelf{}
+	if chatTarget != "" {
+		info, err := ParseLink(chatTarget)
+		if err != nil {
+			return respError(err)
+		}
+		peer, err = b.client.ResolvePeer(context.Background(), info)
+		if err != nil {
+			return respError(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.mu.Lock()
+	a.nextUploadID++
+	task := &UploadTask{
+		ID:       fmt.Sprintf("upload-%d", a.nextUploadID),
+		Path:     path,
+		Target:   chatTarget,
+		Caption:  caption,
+		FileName: filepath.Base(path),
+		Size:     st.Size(),
+		Status:   "running",
+		cancel:   cancel,
+	}
+	if a.uploads == nil {
+		a.uploads = make(map[string]*UploadTask)
+	}
+	a.uploads[task.ID] = task
+	a.mu.Unlock()
+	a.audit.Record("upload", task.ID+" "+path)
+
+	go func() {
+		defer cancel()
+		a.emit("upload:started", task)
+		err := b.client.SendFile(ctx, peer, path, caption, func(uploaded, total int64) {
+			atomic.StoreInt64(&task.Written, uploaded)
+			a.emit("upload:progress", task)
+		})
+		switch {
+		case err == nil:
+			task.setStatus("done")
+			a.emit("upload:done", task)
+			logf("upload %s: finished %s", task.ID, task.FileName)
+		case ctx.Err() != nil:
+			task.setStatus("cancelled")
+			a.emit("upload:cancelled", task)
+		default:
+			task.setStatus("failed")
+			task.setError(err.Error())
+			a.emit("upload:failed", task)
+			logErrorf("upload %s failed: %v", task.ID, err)
+		}
+	}()
+	return respID(task.ID)
+}
+
+// CancelUpload aborts a running upload.
+func (a *App) CancelUpload(id string) Response {
+	a.mu.Lock()
+	task := a.uploads[id]
+	a.mu.Unlock()
+	if task == nil {
+		return respErr(CodeNotFound, "no such upload "+id)
+	}
+	if s := task.status(); s != "running" {
+		return respErr(CodeConflict, "upload is "+s)
+	}
+	task.cancel()
+	return respOK()
+}
+
+// ListUploads returns every upload of this session, running and finished.
+func (a *App) ListUploads() []*UploadTask {
+	a.mu.Lock()
+	out := make([]*UploadTask, 0, len(a.uploads))
+	for _, task := range a.uploads {
+		out = append(out, task)
+	}
+	a.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return uploadNumber(out[i].ID) < uploadNumber(out[j].ID) })
+	return out
+}
+
+func uploadNumber(id string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(id, "upload-"))
+	return n
+}
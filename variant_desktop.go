@@ -0,0 +1,7 @@
+//go:build !headless
+
+package main
+
+// buildVariant names the entry point this binary was built with; see
+// GetCapabilities.
+const buildVariant = "desktop"
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/gotd/td/tg"
+)
+
+// verifyStage runs post-download verification under its own cancel scope,
+// so CancelPostProcessing can stop the hash pass without disturbing the
+// finished transfer it follows.
+func (d *Downloader) verifyStage(ctx context.Context, task *DownloadTask, ref *fileRef) {
+	vctx, cancel := context.WithCancel(ctx)
+	task.setPostCancel(cancel)
+	d.verifyDownload(vctx, task, ref)
+	task.setPostCancel(nil)
+	cancel()
+}
+
+// verifyDownload checks the finished file against Telegram's SHA-256 part
+// hashes from upload.getFileHashes. Mismatching ranges are re-downloaded
+// once and re-checked; the outcome lands in task.Integrity as "verified"
+// or "corrupted".
+func (d *Downloader) verifyDownload(ctx context.Context, task *DownloadTask, ref *fileRef) {
+	path := filepath.Join(task.DestDir, task.FileName)
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		logWarnf("verify %s: %v", task.ID, err)
+		return
+	}
+	defer f.Close()
+
+	bad, err := d.badRanges(ctx, ref, f)
+	if err != nil {
+		logWarnf("verify %s: %v", task.ID, err)
+		return
+	}
+	if len(bad) > 0 {
+		logWarnf("verify %s: %d corrupt ranges, re-downloading", task.ID, len(bad))
+		for _, r := range bad {
+			part, err := d.fetchChunk(ctx, task, ref, r.Offset, r.Limit)
+			if err != nil {
+				break
+			}
+			if _, err := f.WriteAt(part.Bytes, r.Offset); err != nil {
+				break
+			}
+		}
+		if bad, err = d.badRanges(ctx, ref, f); err != nil {
+			logWarnf("verify %s: %v", task.ID, err)
+			return
+		}
+	}
+	if len(bad) == 0 {
+		task.setIntegrity("verified")
+		d.emit("task:verified", task)
+	} else {
+		task.setIntegrity("corrupted")
+		d.emit("task:corrupted", task)
+		logErrorf("verify %s: %d ranges still corrupt", task.ID, len(bad))
+	}
+}
+
+// badRanges walks the server's part hashes and returns the ranges whose
+// local SHA-256 doesn't match.
+func (d *Downloader) badRanges(ctx context.Context, ref *fileRef, f *os.File) ([]tg.FileHash, error) {
+	var bad []tg.FileHash
+	var offset int64
+	buf := make([]byte, 0, defaultPartSize)
+	for {
+		hashes, err := d.client.API().UploadGetFileHashes(ctx, &tg.UploadGetFileHashesRequest{
+			Location: ref.location(),
+			Offset:   offset,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get file hashes: %w", err)
+		}
+		if len(hashes) == 0 {
+			return bad, nil
+		}
+		for _, h := range hashes {
+			if cap(buf) < h.Limit {
+				buf = make([]byte, h.Limit)
+			}
+			chunk := buf[:h.Limit]
+			n, err := f.ReadAt(chunk, h.Offset)
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			sum := sha256.Sum256(chunk[:n])
+			if !bytes.Equal(sum[:], h.Hash) {
+				bad = append(bad, h)
+			}
+			offset = h.Offset + int64(h.Limit)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+)
+
+// SaveDocument streams an arbitrary document to destDir under name.
+func (d *Downloader) SaveDocument(ctx context.Context, doc *tg.Document, destDir, name string) error {
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(destDir, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	loc := &tg.InputDocumentFileLocation{
+		ID:            doc.ID,
+		AccessHash:    doc.AccessHash,
+		FileReference: doc.FileReference,
+	}
+	dl := downloader.NewDownloader().WithPartSize(defaultPartSize)
+	_, err = dl.Download(d.client.API(), loc).Stream(ctx, f)
+	return err
+}
+
+// DownloadWallpapers saves the account's wallpaper gallery into destDir,
+// returning how many files were written.
+func (d *Downloader) DownloadWallpapers(ctx context.Context, destDir string) (int, error) {
+	res, err := d.client.API().AccountGetWallPapers(ctx, 0)
+	if err != nil {
+		return 0, fmt.Errorf("get wallpapers: %w", err)
+	}
+	papers, ok := res.(*tg.AccountWallPapers)
+	if !ok {
+		return 0, nil // not modified
+	}
+	saved := 0
+	for _, wp := range papers.Wallpapers {
+		paper, ok := wp.(*tg.WallPaper)
+		if !ok {
+			continue
+		}
+		doc, ok := paper.Document.AsNotEmpty()
+		if !ok {
+			continue
+		}
+		name := documentFileName(doc)
+		if name == "" {
+			name = paper.Slug + wallpaperExt(doc)
+		}
+		if err := d.SaveDocument(ctx, doc, destDir, name); err != nil {
+			return saved, fmt.Errorf("wallpaper %s: %w", paper.Slug, err)
+		}
+		saved++
+	}
+	return saved, nil
+}
+
+// DownloadWallpaperLink resolves a t.me/bg/<slug> link and saves the
+// wallpaper file.
+func (d *Downloader) DownloadWallpaperLink(ctx context.Context, link, destDir string) (string, error) {
+	slug, err := linkSlug(link, "bg")
+	if err != nil {
+		return "", err
+	}
+	res, err := d.client.API().AccountGetWallPaper(ctx, &tg.InputWallPaperSlug{Slug: slug})
+	if err != nil {
+		return "", fmt.Errorf("get wallpaper %s: %w", slug, err)
+	}
+	paper, ok := res.(*tg.WallPaper)
+	if !ok {
+		return "", fmt.Errorf("wallpaper %s is pattern-only, no file", slug)
+	}
+	doc, ok := paper.Document.AsNotEmpty()
+	if !ok {
+		return "", fmt.Errorf("wallpaper %s has no document", slug)
+	}
+	name := documentFileName(doc)
+	if name == "" {
+		name = slug + wallpaperExt(doc)
+	}
+	return name, d.SaveDocument(ctx, doc, destDir, name)
+}
+
+// DownloadThemeLink resolves a t.me/addtheme/<slug> link and saves the
+// theme file.
+func (d *Downloader) DownloadThemeLink(ctx context.Context, link, destDir string) (string, error) {
+	slug, err := linkSlug(link, "addtheme")
+	if err != nil {
+		return "", err
+	}
+	theme, err := d.client.API().AccountGetTheme(ctx, &tg.AccountGetThemeRequest{
+		Format: "macos",
+		Theme:  &tg.InputThemeSlug{Slug: slug},
+	})
+	if err != nil {
+		return "", fmt.Errorf("get theme %s: %w", slug, err)
+	}
+	doc, ok := theme.Document.(*tg.Document)
+	if !ok {
+		return "", fmt.Errorf("theme %s has no document", slug)
+	}
+	name := documentFileName(doc)
+	if name == "" {
+		name = slug + ".tdesktop-theme"
+	}
+	return name, d.SaveDocument(ctx, doc, destDir, name)
+}
+
+// linkSlug extracts the slug from a t.me/<kind>/<slug> link.
+func linkSlug(raw, kind string) (string, error) {
+	s := strings.TrimSpace(raw)
+	s = strings.TrimPrefix(s, "https://")
+	s = strings.TrimPrefix(s, "http://")
+	prefix := "t.me/" + kind + "/"
+	if !strings.HasPrefix(s, prefix) {
+		return "", fmt.Errorf("not a t.me/%s link: %q", kind, raw)
+	}
+	slug := strings.Trim(s[len(prefix):], "/")
+	if slug == "" {
+		return "", fmt.Errorf("empty slug in %q", raw)
+	}
+	return slug, nil
+}
+
+func wallpaperExt(doc *tg.Document) string {
+	switch doc.MimeType {
+	case "image/png":
+		return ".png"
+	case "application/x-tgwallpattern":
+		return ".tgv"
+	default:
+		return ".jpg"
+	}
+}
@@ -0,0 +1,511 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gotd/td/tg"
+)
+
+// WatchRule describes one channel being watched for new media. Media types
+// is a subset of "video", "document", "photo", "audio"; empty means all.
+type WatchRule struct {
+	ChannelID int64    `json:"channelId"`
+	Username  string   `json:"username"`
+	DestDir   string   `json:"destDir"`
+	Types     []string `json:"types"`
+
+	// Quality overrides the global video quality preference for this
+	// rule ("highest", "lowest", "720p"); empty inherits the global one.
+	Quality string `json:"quality"`
+	// MinSizeBytes skips media smaller than this, filtering out stickers
+	// and preview-sized files in mixed channels. Zero disables the check.
+	MinSizeBytes int64 `json:"minSizeBytes"`
+	// MinFreeBytes reserves free space on the destination volume. A
+	// download that would dip below the reservation pauses the rule
+	// instead of filling the disk. Zero disables the check.
+	MinFreeBytes int64 `json:"minFreeBytes"`
+	// Paused rules are skipped; set automatically when the reservation
+	// is violated, cleared via ResumeWatchRule or a rule update.
+	Paused bool `json:"paused"`
+}
+
+func (r *WatchRule) matchesChannel(id int64) bool {
+	return r.ChannelID == id
+}
+
+func (r *WatchRule) matchesMedia(msg *tg.Message) bool {
+	if len(r.Types) == 0 {
+		return true
+	}
+	kind := mediaKind(msg)
+	for _, t := range r.Types {
+		if t == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *WatchRule) matchesSize(msg *tg.Message) bool {
+	if r.MinSizeBytes == 0 {
+		return true
+	}
+	_, size, _, err := mediaLocation(msg)
+	if err != nil {
+		return true // unsized media is for matchesMedia to filter, not us
+	}
+	return size >= r.MinSizeBytes
+}
+
+// defaultWatchWorkers is the worker-pool size used when none is configured.
+const defaultWatchWorkers = 4
+
+// WatchMetrics is a snapshot of the update worker pool, for the UI and for
+// debugging burst behaviour.
+type WatchMetrics struct {
+	Workers    int   `json:"workers"`
+	QueueDepth int   `json:"queueDepth"`
+	Enqueued   int64 `json:"enqueued"`
+	Processed  int64 `json:"processed"`
+	Failed     int64 `json:"failed"`
+}
+
+type watchJob struct {
+	channelID int64
+	msg       *tg.Message
+}
+
+// Watcher downloads new media posted to watched channels as updates arrive.
+// Message processing runs on a bounded worker pool so a burst of posts in a
+// mirrored channel doesn't block the updates loop.
+type Watcher struct {
+	app        *App
+	client     *TGClient
+	downloader *Downloader
+	account    string
+
+	jobs       chan watchJob
+	poolCancel context.CancelFunc
+
+	enqueued  int64
+	processed int64
+	failed    int64
+
+	mu       sync.Mutex
+	workers  int
+	rules    []WatchRule
+	lastSeen map[int64]int // channel ID -> highest processed message ID
+	recorder string        // external command for live stream hand-off
+}
+
+// NewWatcher registers the update handler on the client's dispatcher and
+// starts the worker pool. Must be called before the client is started.
+func NewWatcher(app *App, client *TGClient, dl *Downloader, account string) *Watcher {
+	w := &Watcher{
+		app:        app,
+		client:     client,
+		downloader: dl,
+		account:    account,
+		jobs:       make(chan watchJob, 256),
+		lastSeen:   make(map[int64]int),
+	}
+	w.loadState()
+	w.SetWorkers(defaultWatchWorkers)
+	client.Dispatcher().OnNewChannelMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateNewChannelMessage) error {
+		return w.handle(ctx, u)
+	})
+	return w
+}
+
+// SetWorkers resizes the processing pool. The queue and its contents are
+// kept; only the consumers are restarted.
+func (w *Watcher) SetWorkers(n int) {
+	if n <= 0 {
+		n = defaultWatchWorkers
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.poolCancel != nil {
+		w.poolCancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.poolCancel = cancel
+	w.workers = n
+	for i := 0; i < n; i++ {
+		go w.worker(ctx)
+	}
+}
+
+func (w *Watcher) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-w.jobs:
+			w.process(ctx, job.channelID, job.msg)
+		}
+	}
+}
+
+// Metrics returns a snapshot of pool activity.
+func (w *Watcher) Metrics() WatchMetrics {
+	w.mu.Lock()
+	workers := w.workers
+	w.mu.Unlock()
+	return WatchMetrics{
+		Workers:    workers,
+		QueueDepth: len(w.jobs),
+		Enqueued:   atomic.LoadInt64(&w.enqueued),
+		Processed:  atomic.LoadInt64(&w.processed),
+		Failed:     atomic.LoadInt64(&w.failed),
+	}
+}
+
+// SetRules replaces the active rule set.
+func (w *Watcher) SetRules(rules []WatchRule) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rules = rules
+}
+
+// AddRule appends one subscription to the active rule set.
+func (w *Watcher) AddRule(rule WatchRule) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rules = append(w.rules, rule)
+}
+
+// RemoveRules drops every rule watching a channel, returning how many
+// were removed.
+func (w *Watcher) RemoveRules(channelID int64) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	kept := w.rules[:0]
+	removed := 0
+	for _, rule := range w.rules {
+		if rule.ChannelID == channelID {
+			removed++
+			continue
+		}
+		kept = append(kept, rule)
+	}
+	w.rules = kept
+	return removed
+}
+
+// warmConcurrency bounds how many resolver calls WarmCache issues at once,
+// to stay under Telegram's resolve rate limits.
+const warmConcurrency = 4
+
+// WarmCache pre-resolves the peers of all configured rules (plus any extra
+// links, e.g. recently queued downloads) so the first update or download
+// doesn't pay resolution latency. Failures are logged and skipped: a rule
+// whose channel can't be resolved yet will still resolve lazily later.
+func (w *Watcher) WarmCache(ctx context.Context, client *TGClient, extra []*LinkInfo) {
+	links := make([]*LinkInfo, 0, len(w.Rules())+len(extra))
+	for _, rule := range w.Rules() {
+		if rule.Username != "" {
+			links = append(links, &LinkInfo{Username: rule.Username})
+		} else {
+			links = append(links, &LinkInfo{ChannelID: rule.ChannelID, Private: true})
+		}
+	}
+	links = append(links, extra...)
+
+	sem := make(chan struct{}, warmConcurrency)
+	var wg sync.WaitGroup
+	for _, link := range links {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(link *LinkInfo) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if _, err := client.ResolveChannel(ctx, link); err != nil {
+				logWarnf("warm: resolve %s/%d failed: %v", link.Username, link.ChannelID, err)
+			}
+		}(link)
+	}
+	wg.Wait()
+}
+
+// Rules returns a copy of the active rule set.
+func (w *Watcher) Rules() []WatchRule {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]WatchRule(nil), w.rules...)
+}
+
+// SetRecorderCommand configures the external tool invoked when a live
+// stream starts in a watched channel; empty disables the hand-off.
+func (w *Watcher) SetRecorderCommand(command string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.recorder = command
+}
+
+func (w *Watcher) recorderCommand() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.recorder
+}
+
+func (w *Watcher) handle(ctx context.Context, u *tg.UpdateNewChannelMessage) error {
+	if svc, ok := u.Message.(*tg.MessageService); ok {
+		if peer, ok := svc.PeerID.(*tg.PeerChannel); ok {
+			w.handleServiceMessage(ctx, peer.ChannelID, svc)
+		}
+		return nil
+	}
+	msg, ok := u.Message.(*tg.Message)
+	if !ok {
+		return nil
+	}
+	peer, ok := msg.PeerID.(*tg.PeerChannel)
+	if !ok {
+		return nil
+	}
+	// Self-destructing media can expire before a backed-up queue gets to
+	// it; capture it immediately instead of going through the pool.
+	if mediaTTL(msg) > 0 {
+		atomic.AddInt64(&w.enqueued, 1)
+		go w.process(ctx, peer.ChannelID, msg)
+		return nil
+	}
+	w.enqueue(watchJob{channelID: peer.ChannelID, msg: msg})
+	return nil
+}
+
+// enqueue hands a message to the worker pool without ever blocking the
+// updates loop: when the queue is full, delivery moves to a goroutine.
+func (w *Watcher) enqueue(job watchJob) {
+	atomic.AddInt64(&w.enqueued, 1)
+	select {
+	case w.jobs <- job:
+	default:
+		go func() { w.jobs <- job }()
+	}
+}
+
+// process applies the rule set to one message from a channel and records it
+// as seen so gap recovery knows where to resume after a restart.
+func (w *Watcher) process(ctx context.Context, channelID int64, msg *tg.Message) {
+	defer w.markSeen(channelID, msg.ID)
+	if _, hasMedia := msg.GetMedia(); !hasMedia {
+		return
+	}
+	if !w.downloader.breaker.Allow(channelID) {
+		return // channel paused by circuit breaker; backfill recovers later
+	}
+	for _, rule := range w.Rules() {
+		if rule.Paused || !rule.matchesChannel(channelID) || !rule.matchesMedia(msg) || !rule.matchesSize(msg) {
+			continue
+		}
+		if !w.checkReservation(rule, msg) {
+			continue
+		}
+		link := &LinkInfo{ChannelID: channelID, MessageID: msg.ID, Private: true}
+		task := w.app.newTask(link, rule.DestDir, w.account)
+		if rule.Quality != "" {
+			task.Quality = rule.Quality
+		}
+		if err := w.downloader.Run(ctx, task); err != nil {
+			atomic.AddInt64(&w.failed, 1)
+			logErrorf("watch: download from %d failed: %v", channelID, err)
+		}
+	}
+	atomic.AddInt64(&w.processed, 1)
+}
+
+// Backfill fetches everything posted to watched channels since the last
+// message each one was seen with, running missed posts through the normal
+// rule pipeline. Call it after connecting, before relying on live updates.
+func (w *Watcher) Backfill(ctx context.Context) {
+	for _, rule := range w.Rules() {
+		w.mu.Lock()
+		since := w.lastSeen[rule.ChannelID]
+		w.mu.Unlock()
+		if since == 0 {
+			continue // never seen this channel; don't replay its history
+		}
+		if err := w.backfillChannel(ctx, rule, since); err != nil {
+			logErrorf("watch: backfill of %d failed: %v", rule.ChannelID, err)
+		}
+	}
+}
+
+func (w *Watcher) backfillChannel(ctx context.Context, rule WatchRule, since int) error {
+	link := &LinkInfo{ChannelID: rule.ChannelID, Username: rule.Username, Private: rule.Username == ""}
+	ch, err := w.client.ResolveChannel(ctx, link)
+	if err != nil {
+		return err
+	}
+	peer := &tg.InputPeerChannel{ChannelID: ch.ChannelID, AccessHash: ch.AccessHash}
+
+	// Walk history newest-first until we pass the last seen ID, then
+	// process the missed messages oldest-first.
+	var missed []*tg.Message
+	offsetID := 0
+	for {
+		res, err := w.client.API().MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+			Peer:     peer,
+			OffsetID: offsetID,
+			MinID:    since,
+			Limit:    100,
+		})
+		if err != nil {
+			return err
+		}
+		msgs, ok := res.(*tg.MessagesChannelMessages)
+		if !ok {
+			return fmt.Errorf("unexpected history type %T", res)
+		}
+		if len(msgs.Messages) == 0 {
+			break
+		}
+		progressed := false
+		for _, m := range msgs.Messages {
+			msg, ok := m.(*tg.Message)
+			if !ok {
+				continue
+			}
+			offsetID = msg.ID
+			progressed = true
+			if msg.ID > since {
+				missed = append(missed, msg)
+			}
+		}
+		if !progressed {
+			break
+		}
+	}
+	for i := len(missed) - 1; i >= 0; i-- {
+		w.process(ctx, ch.ChannelID, missed[i])
+	}
+	return nil
+}
+
+// checkReservation verifies downloading msg would not dip below the rule's
+// free-space reservation on its destination volume. On violation the rule
+// is paused and an alert emitted; the message itself is not lost, it will
+// be backfilled once the rule resumes.
+func (w *Watcher) checkReservation(rule WatchRule, msg *tg.Message) bool {
+	if rule.MinFreeBytes == 0 {
+		return true
+	}
+	free, err := freeSpace(rule.DestDir)
+	if err != nil {
+		logWarnf("watch: free space check for %s: %v", rule.DestDir, err)
+		return true
+	}
+	var need int64
+	if _, size, _, err := mediaLocation(msg); err == nil {
+		need = size
+	}
+	if free-need >= rule.MinFreeBytes {
+		return true
+	}
+	w.pauseRule(rule.ChannelID)
+	w.app.emit("watch:alert", map[string]interface{}{
+		"channelId": rule.ChannelID,
+		"destDir":   rule.DestDir,
+		"freeBytes": free,
+		"reason":    "free-space reservation violated",
+	})
+	return false
+}
+
+// pauseRule marks all rules for a channel as paused.
+func (w *Watcher) pauseRule(channelID int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i := range w.rules {
+		if w.rules[i].ChannelID == channelID {
+			w.rules[i].Paused = true
+		}
+	}
+}
+
+// ResumeRule clears the paused flag on all rules for a channel.
+func (w *Watcher) ResumeRule(channelID int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i := range w.rules {
+		if w.rules[i].ChannelID == channelID {
+			w.rules[i].Paused = false
+		}
+	}
+}
+
+const watchStateName = "watch-state.json"
+
+func (w *Watcher) statePath() string {
+	return filepath.Join(w.client.StateDir(), watchStateName)
+}
+
+// markSeen advances the per-channel high-water mark and persists it.
+func (w *Watcher) markSeen(channelID int64, msgID int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if msgID <= w.lastSeen[channelID] {
+		return
+	}
+	w.lastSeen[channelID] = msgID
+	w.saveStateLocked()
+}
+
+func (w *Watcher) loadState() {
+	data, err := os.ReadFile(w.statePath())
+	if err != nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := json.Unmarshal(data, &w.lastSeen); err != nil {
+		logWarnf("watch: corrupt state file, starting fresh: %v", err)
+		w.lastSeen = make(map[int64]int)
+	}
+}
+
+func (w *Watcher) saveStateLocked() {
+	data, err := json.Marshal(w.lastSeen)
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(w.statePath(), data, 0o600); err != nil {
+		logErrorf("watch: save state: %v", err)
+	}
+}
+
+// mediaKind classifies a message's media for rule filtering.
+func mediaKind(msg *tg.Message) string {
+	media, ok := msg.GetMedia()
+	if !ok {
+		return ""
+	}
+	switch m := media.(type) {
+	case *tg.MessageMediaPhoto:
+		return "photo"
+	case *tg.MessageMediaDocument:
+		doc, ok := m.Document.AsNotEmpty()
+		if !ok {
+			return ""
+		}
+		for _, attr := range doc.Attributes {
+			switch attr.(type) {
+			case *tg.DocumentAttributeVideo:
+				return "video"
+			case *tg.DocumentAttributeAudio:
+				return "audio"
+			}
+		}
+		return "document"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,27 @@
+//go:build headless
+
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// dashboardHTML is the whole web UI: one self-contained page talking to
+// the same REST and WebSocket APIs any remote frontend would use, so
+// headless deployments get queue management without the desktop app.
+//
+//go:embed webui.html
+var dashboardHTML []byte
+
+// handleIndex serves the embedded dashboard. The page itself is static
+// and carries no state; every API call it makes is token-authenticated
+// like any other client.
+func (s *apiServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}